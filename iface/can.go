@@ -0,0 +1,137 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// canKey is namespace element under which CAN protocol statistics are published
+	canKey = "_can"
+	// canStat is namespace element under which CAN interface statistics are published
+	canStat = "can"
+
+	// IFLA_INFO_DATA attributes of CAN devices, see linux/can/netlink.h
+	iflaCanState       = 4
+	iflaCanBerrCounter = 8
+)
+
+var canInfo = "/proc/net/can/stats"
+
+// canDeviceStats lists fields of struct can_device_stats kept in IFLA_INFO_XSTATS
+var canDeviceStats = []string{
+	"bus_error", "error_warning", "error_passive", "bus_off", "arbitration_lost", "restarts",
+}
+
+// getCANStats stores CAN protocol statistics under canKey and statistics of
+// CAN interfaces within their namespaces, e.g. /intel/procfs/iface/can0/can/state
+func getCANStats(stats map[string]interface{}, cfg config, _ *sourceState) error {
+	links, err := getLinks()
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		istats, ok := stats[l.name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if c := canLinkStats(l); c != nil {
+			istats[canStat] = c
+		}
+	}
+
+	// protocol statistics are available once CAN protocol module is loaded
+	pstats, err := readCANProcStats(canInfo)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	stats[canKey] = pstats
+	return nil
+}
+
+// canLinkStats returns bus state, error counters and device statistics of CAN
+// interface, nil for other interfaces including virtual CAN ones
+func canLinkStats(l link) map[string]interface{} {
+	if l.kind != "can" {
+		return nil
+	}
+
+	c := map[string]interface{}{}
+	if b := l.data[iflaCanState]; len(b) == 4 {
+		c["state"] = int64(nativeEndian.Uint32(b))
+	}
+	if b := l.data[iflaCanBerrCounter]; len(b) == 4 {
+		c["tx_errors"] = int64(nativeEndian.Uint16(b[0:2]))
+		c["rx_errors"] = int64(nativeEndian.Uint16(b[2:4]))
+	}
+	if len(l.xstats) >= 4*len(canDeviceStats) {
+		for i, name := range canDeviceStats {
+			c[name] = int64(nativeEndian.Uint32(l.xstats[4*i:]))
+		}
+	}
+	return c
+}
+
+// readCANProcStats parses lines of format "1234 frames/s total tx rate (TXR)",
+// statistics are named by description without units, e.g. total_tx_rate
+func readCANProcStats(path string) (map[string]interface{}, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	stats := map[string]interface{}{}
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		val, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		words := []string{}
+		for _, f := range fields[1:] {
+			if strings.HasPrefix(f, "(") {
+				break
+			}
+			if f == "%" || f == "frames/s" {
+				continue
+			}
+			words = append(words, f)
+		}
+		if len(words) > 0 {
+			stats[strings.Join(words, "_")] = val
+		}
+	}
+	return stats, scanner.Err()
+}
@@ -0,0 +1,119 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"syscall"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	afCAN  = 29
+	canRaw = 1
+)
+
+// addLink creates interface of given kind without kind specific attributes
+func addLink(name, kind string) error {
+	return newLink(name, kind, nil, nil)
+}
+
+func TestCANLinkStats(t *testing.T) {
+	Convey("Given recorded link dump with CAN interfaces", t, func() {
+		links, err := readLinks("../examples/test/rtnl.can.links")
+		So(err, ShouldBeNil)
+		So(len(links), ShouldEqual, 3)
+
+		Convey("When CAN statistics are read", func() {
+			can0 := canLinkStats(links[1])
+			vcan0 := canLinkStats(links[2])
+
+			Convey("Bus state and error counters of CAN interface are returned", func() {
+				So(can0, ShouldResemble, map[string]interface{}{
+					"state":            int64(2),
+					"tx_errors":        int64(136),
+					"rx_errors":        int64(5),
+					"bus_error":        int64(1532),
+					"error_warning":    int64(12),
+					"error_passive":    int64(4),
+					"bus_off":          int64(1),
+					"arbitration_lost": int64(37),
+					"restarts":         int64(1),
+				})
+			})
+
+			Convey("Virtual CAN interface has no bus statistics", func() {
+				So(vcan0, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestReadCANProcStats(t *testing.T) {
+	Convey("Given CAN protocol statistics", t, func() {
+		Convey("When they are parsed", func() {
+			stats, err := readCANProcStats("../examples/test/proc.net.can.stats")
+			So(err, ShouldBeNil)
+
+			Convey("Statistics are named by their descriptions", func() {
+				So(len(stats), ShouldEqual, 15)
+				So(stats["transmitted_frames"], ShouldEqual, 48213)
+				So(stats["received_frames"], ShouldEqual, 150077)
+				So(stats["total_match_ratio"], ShouldEqual, 62)
+				So(stats["max_rx_rate"], ShouldEqual, 1200)
+				So(stats["current_receive_list_entries"], ShouldEqual, 4)
+				So(stats["statistic_resets"], ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestGetCANStatsNetns(t *testing.T) {
+	if !runInNetns(t, "TestGetCANStatsNetns") {
+		return
+	}
+	if err := addLink("vcan0", "vcan"); err != nil {
+		t.Skip("Virtual CAN interfaces are not available, ", err)
+	}
+	fd, err := syscall.Socket(afCAN, syscall.SOCK_RAW, canRaw)
+	if err != nil {
+		t.Skip("CAN protocol is not available, ", err)
+	}
+	syscall.Close(fd)
+
+	Convey("Given network namespace with virtual CAN interface", t, func() {
+		stats := map[string]interface{}{}
+		So(getStats(stats, statsBackendProcfs), ShouldBeNil)
+		So(stats, ShouldContainKey, "vcan0")
+
+		Convey("When CAN statistics are collected", func() {
+			err := getCANStats(stats, config{}, nil)
+
+			Convey("Protocol statistics are published", func() {
+				So(err, ShouldBeNil)
+				So(stats[canKey], ShouldContainKey, "transmitted_frames")
+				So(stats["vcan0"], ShouldNotContainKey, canStat)
+			})
+		})
+	})
+}
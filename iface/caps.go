@@ -0,0 +1,111 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// availabilityKey is namespace element of availability gauges of sources
+	availabilityKey = "_sources"
+
+	// capabilities of linux/capability.h required by sources
+	capNetAdmin = 12
+	capNetRaw   = 13
+	capSysAdmin = 21
+)
+
+var capNames = map[uint]string{
+	capNetAdmin: "CAP_NET_ADMIN",
+	capNetRaw:   "CAP_NET_RAW",
+	capSysAdmin: "CAP_SYS_ADMIN",
+}
+
+var procStatus = "/proc/self/status"
+
+// effectiveCaps reads effective capability set of the plugin process
+func effectiveCaps(path string) (uint64, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "CapEff:" {
+			return strconv.ParseUint(fields[1], 16, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("No effective capabilities in {%s}", path)
+}
+
+// privileged lists sources and entering of network namespaces,
+// which need capabilities
+func privileged() []source {
+	return append(append([]source{}, sources...), source{key: netnsKey, caps: []uint{capSysAdmin}})
+}
+
+// disabledSources returns names of capabilities which are required by sources
+// and missing in caps, indexed by source key
+func disabledSources(caps uint64) map[string][]string {
+	disabled := map[string][]string{}
+	for _, s := range privileged() {
+		for _, c := range s.caps {
+			if caps&(1<<c) == 0 {
+				disabled[s.key] = append(disabled[s.key], capNames[c])
+			}
+		}
+	}
+	return disabled
+}
+
+// sourceName returns element of availability gauge namespace for source
+func sourceName(key string) string {
+	return strings.TrimPrefix(key, "_")
+}
+
+// setAvailability publishes availability gauges of sources checked so far
+func (iface *ifacePlugin) setAvailability() {
+	if len(iface.available) == 0 {
+		delete(iface.stats, availabilityKey)
+		return
+	}
+	gauges := map[string]interface{}{}
+	for key, ok := range iface.available {
+		val := int64(0)
+		if ok {
+			val = 1
+		}
+		gauges[sourceName(key)] = map[string]interface{}{"available": val}
+	}
+	iface.stats[availabilityKey] = gauges
+}
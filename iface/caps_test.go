@@ -0,0 +1,118 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestEffectiveCaps(t *testing.T) {
+	Convey("Given status of process with CAP_NET_RAW only", t, func() {
+		Convey("When capabilities are read", func() {
+			caps, err := effectiveCaps("../examples/test/proc.self.status")
+			So(err, ShouldBeNil)
+
+			Convey("Effective set is returned", func() {
+				So(caps, ShouldEqual, uint64(1)<<capNetRaw)
+			})
+
+			Convey("Sources which need CAP_NET_ADMIN and CAP_SYS_ADMIN are disabled", func() {
+				So(disabledSources(caps), ShouldResemble, map[string][]string{
+					conntrackKey: {"CAP_NET_ADMIN"},
+					firewallKey:  {"CAP_NET_ADMIN"},
+					netnsKey:     {"CAP_SYS_ADMIN"},
+				})
+			})
+		})
+	})
+}
+
+func TestSourceAvailability(t *testing.T) {
+	Convey("Given plugin with conntrack source enabled", t, func() {
+		ifaceInfo = "../examples/test/proc.net.dev"
+		conntrackInfo = "../examples/test/proc.net.nf_conntrack"
+		defer func() { ifaceInfo, conntrackInfo = "/proc/net/dev", "/proc/net/nf_conntrack" }()
+		node := cdata.NewNode()
+		node.AddItem("conntrack_entries", ctypes.ConfigValueBool{Value: true})
+		mTypes := []plugin.PluginMetricType{}
+		for _, ns := range []string{"lo/bytes_recv", "_conntrack/entries/total", "_sources/conntrack/available"} {
+			mTypes = append(mTypes, plugin.PluginMetricType{Namespace_: strings.Split("intel/procfs/iface/"+ns, "/"), Config_: node})
+		}
+		collect := func(ifacePlg *ifacePlugin) map[string]interface{} {
+			metrics, err := ifacePlg.CollectMetrics(mTypes)
+			So(err, ShouldBeNil)
+			values := map[string]interface{}{}
+			for _, m := range metrics {
+				values[strings.Join(m.Namespace()[3:], "/")] = m.Data_
+			}
+			return values
+		}
+
+		Convey("When plugin lacks capabilities of the source", func() {
+			ifacePlg := &ifacePlugin{stats: map[string]interface{}{}, disabled: disabledSources(1 << capNetRaw)}
+
+			Convey("Source is left out of catalog", func() {
+				mts, err := ifacePlg.GetMetricTypes(plugin.PluginConfigType{ConfigDataNode: node})
+				So(err, ShouldBeNil)
+				namespaces := []string{}
+				for _, m := range mts {
+					namespaces = append(namespaces, strings.Join(m.Namespace()[3:], "/"))
+				}
+				So(namespaces, ShouldContain, "_sources/conntrack/available")
+				So(namespaces, ShouldNotContain, "_conntrack/entries/total")
+			})
+
+			Convey("Other metrics are collected and source is reported unavailable", func() {
+				values := collect(ifacePlg)
+				So(values["lo/bytes_recv"], ShouldEqual, 5006527952)
+				So(values["_conntrack/entries/total"], ShouldBeNil)
+				So(values["_sources/conntrack/available"], ShouldEqual, 0)
+			})
+		})
+
+		Convey("When source fails", func() {
+			ifacePlg := &ifacePlugin{stats: map[string]interface{}{}}
+			conntrackInfo = "../examples/test/proc.net.nf_conntrack.gone"
+			values := collect(ifacePlg)
+
+			Convey("Other metrics are collected and source is reported unavailable", func() {
+				So(values["lo/bytes_recv"], ShouldEqual, 5006527952)
+				So(values["_conntrack/entries/total"], ShouldBeNil)
+				So(values["_sources/conntrack/available"], ShouldEqual, 0)
+			})
+
+			Convey("Source is reported available once it recovers", func() {
+				conntrackInfo = "../examples/test/proc.net.nf_conntrack"
+				values := collect(ifacePlg)
+				So(values["_conntrack/entries/total"], ShouldEqual, 7)
+				So(values["_sources/conntrack/available"], ShouldEqual, 1)
+			})
+		})
+	})
+}
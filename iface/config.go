@@ -0,0 +1,262 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// config gives typed access to plugin configuration items,
+// items which are missing or have unexpected type are replaced by defaults
+type config map[string]ctypes.ConfigValue
+
+// newConfig creates config from config data node with items of selected preset
+// added, nil node results in empty config
+func newConfig(node *cdata.ConfigDataNode) config {
+	if node == nil {
+		return config{}
+	}
+	c, err := config(node.Table()).withPreset()
+	if err != nil {
+		log.Warn("Preset is ignored, ", err)
+	}
+	return c
+}
+
+// overrideSep separates config item from regular expression of interface
+// names it overrides config of, e.g. vm_tags@^vnet[0-9]+$
+const overrideSep = "@"
+
+// interfaceItems are config items which may differ per interface, sources
+// which publish outside of interface namespaces, e.g. tcp connections,
+// resolve them for interface the measured traffic belongs to
+var interfaceItems = map[string]bool{
+	"tunnel_tags":         true,
+	"vm_tags":             true,
+	"vrf":                 true,
+	"link_details":        true,
+	"tcp_aggregate":       true,
+	"tcp_prefix_v4":       true,
+	"tcp_prefix_v6":       true,
+	"packet_sample_ports": true,
+}
+
+// metricsConfig returns config of requested metrics, items which apply to all
+// interfaces are expected to be set for the plugin so first metric is used
+func metricsConfig(mts []plugin.PluginMetricType) config {
+	if len(mts) == 0 {
+		return config{}
+	}
+	return newConfig(mts[0].Config())
+}
+
+func (c config) getBool(key string, def bool) bool {
+	if v, ok := c[key].(ctypes.ConfigValueBool); ok {
+		return v.Value
+	}
+	return def
+}
+
+func (c config) getInt(key string, def int) int {
+	if v, ok := c[key].(ctypes.ConfigValueInt); ok {
+		return v.Value
+	}
+	return def
+}
+
+func (c config) getString(key string, def string) string {
+	if v, ok := c[key].(ctypes.ConfigValueStr); ok {
+		return v.Value
+	}
+	return def
+}
+
+// interfaceConfigs returns config of each interface of requested metrics,
+// snap resolves config along namespace so items set for /intel/procfs/iface/<iface>
+// override items set for /intel/procfs/iface, overrides keyed by regular
+// expression are applied on top of it
+func interfaceConfigs(mts []plugin.PluginMetricType) (map[string]config, error) {
+	configs := map[string]config{}
+	for _, mt := range mts {
+		ns := mt.Namespace()
+		if len(ns) < 4 || strings.HasPrefix(ns[3], "_") {
+			continue
+		}
+		if _, ok := configs[ns[3]]; ok {
+			continue
+		}
+		cfg, err := newConfig(mt.Config()).forInterface(ns[3])
+		if err != nil {
+			return nil, err
+		}
+		configs[ns[3]] = cfg
+	}
+	return configs, nil
+}
+
+// forInterface returns config with items keyed item@regexp replaced by their
+// values when regular expression matches interface name, the most specific
+// matching override of item wins, see overrideRank
+func (c config) forInterface(name string) (config, error) {
+	overrides := byRank{}
+	for k := range c {
+		if !strings.Contains(k, overrideSep) {
+			continue
+		}
+		item, re, err := parseOverride(k)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, override{key: k, item: item, re: re, rank: overrideRank(re)})
+	}
+	if len(overrides) == 0 {
+		return c, nil
+	}
+	// less specific overrides are applied first
+	sort.Sort(overrides)
+
+	res := config{}
+	for k, v := range c {
+		res[k] = v
+	}
+	for _, o := range overrides {
+		if o.re.MatchString(name) {
+			res[o.item] = c[o.key]
+		}
+	}
+	return res, nil
+}
+
+// override is config item set for interfaces matching regular expression
+type override struct {
+	key  string
+	item string
+	re   *regexp.Regexp
+	rank [3]int
+}
+
+type byRank []override
+
+func (s byRank) Len() int      { return len(s) }
+func (s byRank) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byRank) Less(i, j int) bool {
+	for k := range s[i].rank {
+		if s[i].rank[k] != s[j].rank[k] {
+			return s[i].rank[k] < s[j].rank[k]
+		}
+	}
+	return s[i].key < s[j].key
+}
+
+// overrideRank orders overrides by specificity of their regular expression:
+// exact interface name, e.g. ^eth0$, beats expressions anchored at both ends,
+// which beat those anchored at one end and unanchored ones; expressions with
+// the same anchoring are ordered by length of their literal prefix, remaining
+// ties by override key
+func overrideRank(re *regexp.Regexp) [3]int {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return [3]int{}
+	}
+	subs := []*syntax.Regexp{parsed}
+	if parsed.Op == syntax.OpConcat {
+		subs = parsed.Sub
+	}
+	begin := subs[0].Op == syntax.OpBeginText || subs[0].Op == syntax.OpBeginLine
+	end := subs[len(subs)-1].Op == syntax.OpEndText || subs[len(subs)-1].Op == syntax.OpEndLine
+
+	rank := [3]int{}
+	if begin && end && len(subs) == 3 && subs[1].Op == syntax.OpLiteral && subs[1].Flags&syntax.FoldCase == 0 {
+		rank[0] = 1
+	}
+	for _, anchored := range []bool{begin, end} {
+		if anchored {
+			rank[1]++
+		}
+	}
+	prefix, _ := re.LiteralPrefix()
+	rank[2] = len(prefix)
+	return rank
+}
+
+// perInterface returns function which resolves config of interface by
+// overrides of config, config of each interface is resolved once
+func (c config) perInterface() (func(name string) config, error) {
+	// wrong overrides are reported before any interface is resolved
+	if _, err := c.forInterface(""); err != nil {
+		return nil, err
+	}
+	configs := map[string]config{}
+	return func(name string) config {
+		cfg, ok := configs[name]
+		if !ok {
+			cfg, _ = c.forInterface(name)
+			configs[name] = cfg
+		}
+		return cfg
+	}, nil
+}
+
+// anyInterface reports whether boolean item is enabled in config
+// or in any of its overrides
+func (c config) anyInterface(item string) bool {
+	for k := range c {
+		if strings.SplitN(k, overrideSep, 2)[0] == item && c.getBool(k, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// itemSpec returns values of item and of its overrides in comparable form
+func (c config) itemSpec(item string) string {
+	values := []string{}
+	for k, v := range c {
+		if strings.SplitN(k, overrideSep, 2)[0] == item {
+			values = append(values, fmt.Sprintf("%s=%v", k, v))
+		}
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+// parseOverride splits key of override into config item and regular expression
+func parseOverride(key string) (string, *regexp.Regexp, error) {
+	kv := strings.SplitN(key, overrideSep, 2)
+	if !interfaceItems[kv[0]] {
+		return "", nil, fmt.Errorf("Config item {%s} cannot differ per interface", kv[0])
+	}
+	re, err := regexp.Compile(kv[1])
+	if err != nil {
+		return "", nil, fmt.Errorf("Wrong regular expression of config item {%s}, %v", key, err)
+	}
+	return kv[0], re, nil
+}
@@ -0,0 +1,153 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestInterfaceConfigs(t *testing.T) {
+	Convey("Given plugin config with overrides of interfaces", t, func() {
+		node := cdata.NewNode()
+		node.AddItem("vm_tags", ctypes.ConfigValueBool{Value: true})
+		node.AddItem("tunnel_tags@^vxlan", ctypes.ConfigValueBool{Value: true})
+		node.AddItem("vm_tags@^(vnet|tap)", ctypes.ConfigValueBool{Value: false})
+		node.AddItem("vm_tags@^vnet1$", ctypes.ConfigValueBool{Value: true})
+		// broad override sorts after exact one by name
+		node.AddItem("tunnel_tags@gre", ctypes.ConfigValueBool{Value: true})
+		node.AddItem("tunnel_tags@^gre0$", ctypes.ConfigValueBool{Value: false})
+		node.AddItem("link_details@^br-lan", ctypes.ConfigValueBool{Value: true})
+		node.AddItem("link_details@^br", ctypes.ConfigValueBool{Value: false})
+
+		// snap merges config of interface namespace into plugin config
+		eth0 := cdata.NewNode()
+		for k, v := range node.Table() {
+			eth0.AddItem(k, v)
+		}
+		eth0.AddItem("vm_tags", ctypes.ConfigValueBool{Value: false})
+
+		mts := []plugin.PluginMetricType{}
+		for _, ns := range []string{"eth0/bytes_recv", "vxlan100/bytes_recv", "vnet0/bytes_recv", "vnet1/bytes_recv", "gre0/bytes_recv", "gre1/bytes_recv", "br-lan1/bytes_recv", "br0/bytes_recv", "_tcp/port/22/rtt_p50"} {
+			cfg := node
+			if strings.HasPrefix(ns, "eth0") {
+				cfg = eth0
+			}
+			mts = append(mts, plugin.PluginMetricType{Namespace_: strings.Split("intel/procfs/iface/"+ns, "/"), Config_: cfg})
+		}
+
+		Convey("When config of interfaces is resolved", func() {
+			configs, err := interfaceConfigs(mts)
+			So(err, ShouldBeNil)
+
+			Convey("Config of interface namespace is used", func() {
+				So(configs["eth0"].getBool("vm_tags", true), ShouldBeFalse)
+				So(configs["eth0"].getBool("tunnel_tags", false), ShouldBeFalse)
+			})
+
+			Convey("Matching overrides are applied", func() {
+				So(configs["vxlan100"].getBool("tunnel_tags", false), ShouldBeTrue)
+				So(configs["vxlan100"].getBool("vm_tags", false), ShouldBeTrue)
+				So(configs["vnet0"].getBool("vm_tags", true), ShouldBeFalse)
+			})
+
+			Convey("Most specific matching override wins", func() {
+				So(configs["vnet1"].getBool("vm_tags", false), ShouldBeTrue)
+				So(configs["gre0"].getBool("tunnel_tags", true), ShouldBeFalse)
+				So(configs["gre1"].getBool("tunnel_tags", false), ShouldBeTrue)
+				So(configs["br-lan1"].getBool("link_details", false), ShouldBeTrue)
+				So(configs["br0"].getBool("link_details", true), ShouldBeFalse)
+			})
+
+			Convey("Sources have no interface config", func() {
+				So(configs, ShouldNotContainKey, "_tcp")
+			})
+		})
+
+		Convey("When override has wrong regular expression", func() {
+			node.AddItem("vrf@(eth", ctypes.ConfigValueBool{Value: true})
+			_, err := interfaceConfigs(mts)
+
+			Convey("Error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When override sets item which applies to all interfaces", func() {
+			node.AddItem("max_elements@eth", ctypes.ConfigValueInt{Value: 1})
+			_, err := interfaceConfigs(mts)
+
+			Convey("Error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestPerInterface(t *testing.T) {
+	Convey("Given config with overrides of source items", t, func() {
+		cfg := config{
+			"tcp_prefix_v4":           ctypes.ConfigValueInt{Value: 24},
+			"tcp_prefix_v4@^stor":     ctypes.ConfigValueInt{Value: 28},
+			"link_details@^eth":       ctypes.ConfigValueBool{Value: true},
+			"packet_sample_ports@^up": ctypes.ConfigValueStr{Value: "443"},
+		}
+
+		Convey("When config of interfaces is resolved", func() {
+			resolve, err := cfg.perInterface()
+			So(err, ShouldBeNil)
+
+			Convey("Overrides of matching interfaces are applied", func() {
+				So(resolve("stor0").getInt("tcp_prefix_v4", 0), ShouldEqual, 28)
+				So(resolve("eth0").getInt("tcp_prefix_v4", 0), ShouldEqual, 24)
+				So(resolve("eth0").getBool("link_details", false), ShouldBeTrue)
+			})
+		})
+
+		Convey("Item enabled only by override is enabled for some interface", func() {
+			So(cfg.anyInterface("link_details"), ShouldBeTrue)
+			So(cfg.anyInterface("vrf"), ShouldBeFalse)
+		})
+
+		Convey("Item with overrides has spec which changes with them", func() {
+			spec := cfg.itemSpec("packet_sample_ports")
+			So(spec, ShouldNotBeEmpty)
+			cfg["packet_sample_ports@^up"] = ctypes.ConfigValueStr{Value: "8443"}
+			So(cfg.itemSpec("packet_sample_ports"), ShouldNotEqual, spec)
+		})
+
+		Convey("When override has wrong regular expression", func() {
+			cfg["tcp_aggregate@(stor"] = ctypes.ConfigValueStr{Value: aggregateBySubnet}
+			_, err := cfg.perInterface()
+
+			Convey("Error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
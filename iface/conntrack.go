@@ -0,0 +1,482 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+const (
+	// conntrackKey is namespace element under which conntrack metrics are published
+	conntrackKey = "_conntrack"
+)
+
+var conntrackInfo = "/proc/net/nf_conntrack"
+
+// conntrackEntry holds fields of single /proc/net/nf_conntrack line,
+// byte slices are valid only until next line is read
+type conntrackEntry struct {
+	l3    []byte
+	proto []byte
+	// state is protocol state, e.g. TIME_WAIT, empty for stateless protocols
+	state []byte
+	// src, dst, sport and dport describe original direction
+	src   []byte
+	dst   []byte
+	sport []byte
+	dport []byte
+	mark  []byte
+	zone  []byte
+	// packets and bytes are summed for both directions
+	packets   uint64
+	bytes     uint64
+	acct      bool
+	unreplied bool
+	assured   bool
+}
+
+var (
+	ctSrc       = []byte("src")
+	ctDst       = []byte("dst")
+	ctSport     = []byte("sport")
+	ctDport     = []byte("dport")
+	ctPackets   = []byte("packets")
+	ctBytes     = []byte("bytes")
+	ctMark      = []byte("mark")
+	ctZone      = []byte("zone")
+	ctUnreplied = []byte("[UNREPLIED]")
+	ctAssured   = []byte("[ASSURED]")
+	ctTCP       = []byte("tcp")
+)
+
+// readConntrack streams through conntrack table calling fn for each entry
+func readConntrack(path string, fn func(e *conntrackEntry)) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	r := bufio.NewReaderSize(fh, 64*1024)
+	e := &conntrackEntry{}
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return fmt.Errorf("Conntrack entry is too long {%s}", line)
+		}
+		if len(line) > 0 {
+			if perr := parseConntrackLine(line, e); perr != nil {
+				return perr
+			}
+			fn(e)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseConntrackLine parses line of format
+// ipv4 2 tcp 6 117 TIME_WAIT src=10.0.0.1 dst=10.0.0.2 sport=22 dport=5000 packets=3 bytes=180 src=... [ASSURED] mark=0 zone=0 use=2
+func parseConntrackLine(line []byte, e *conntrackEntry) error {
+	*e = conntrackEntry{}
+
+	field := 0
+	for len(line) > 0 {
+		i := bytes.IndexByte(line, ' ')
+		var tok []byte
+		if i < 0 {
+			tok, line = line, nil
+		} else {
+			tok, line = line[:i], line[i+1:]
+		}
+		tok = bytes.TrimRight(tok, "\n")
+		if len(tok) == 0 {
+			continue
+		}
+
+		switch field {
+		case 0:
+			e.l3 = tok
+		case 2:
+			e.proto = tok
+		}
+		field++
+		if field <= 5 {
+			continue
+		}
+
+		eq := bytes.IndexByte(tok, '=')
+		if eq < 0 {
+			switch {
+			case tok[0] != '[':
+				e.state = tok
+			case bytes.Equal(tok, ctUnreplied):
+				e.unreplied = true
+			case bytes.Equal(tok, ctAssured):
+				e.assured = true
+			}
+			continue
+		}
+
+		key, val := tok[:eq], tok[eq+1:]
+		switch {
+		case bytes.Equal(key, ctSrc):
+			if e.src == nil {
+				e.src = val
+			}
+		case bytes.Equal(key, ctDst):
+			if e.dst == nil {
+				e.dst = val
+			}
+		case bytes.Equal(key, ctSport):
+			if e.sport == nil {
+				e.sport = val
+			}
+		case bytes.Equal(key, ctDport):
+			if e.dport == nil {
+				e.dport = val
+			}
+		case bytes.Equal(key, ctPackets):
+			e.packets += parseUint(val)
+			e.acct = true
+		case bytes.Equal(key, ctBytes):
+			e.bytes += parseUint(val)
+			e.acct = true
+		case bytes.Equal(key, ctMark):
+			e.mark = val
+		case bytes.Equal(key, ctZone):
+			e.zone = val
+		}
+	}
+
+	if field < 5 {
+		return fmt.Errorf("Wrong conntrack entry format {%s}", e.l3)
+	}
+	return nil
+}
+
+// parseUint parses decimal number without allocations, invalid digits end it
+func parseUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			break
+		}
+		v = v*10 + uint64(c-'0')
+	}
+	return v
+}
+
+// talker is flow or address with number of bytes transferred within interval
+type talker struct {
+	key     string
+	tags    map[string]string
+	bytes   uint64
+	packets uint64
+}
+
+type byBytes []*talker
+
+func (s byBytes) Len() int { return len(s) }
+func (s byBytes) Less(i, j int) bool {
+	if s[i].bytes == s[j].bytes {
+		return s[i].key < s[j].key
+	}
+	return s[i].bytes > s[j].bytes
+}
+func (s byBytes) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// tcpStates lists TCP conntrack states which are always published,
+// so metric catalog does not depend on current connections
+var tcpStates = []string{
+	"NONE", "SYN_SENT", "SYN_RECV", "ESTABLISHED", "FIN_WAIT",
+	"CLOSE_WAIT", "LAST_ACK", "TIME_WAIT", "CLOSE", "SYN_SENT2",
+}
+
+// l4Protos lists protocols which are always published
+var l4Protos = []string{"tcp", "udp", "icmp", "icmpv6"}
+
+// getConntrackStats streams once through conntrack table and stores
+// metrics of enabled conntrack features in stats map under conntrackKey,
+// top talkers are ranked against counters kept in state of previous
+// collection, so all ranks are empty when state is nil or new
+func getConntrackStats(stats map[string]interface{}, cfg config, state *sourceState) error {
+	var fns []func(e *conntrackEntry)
+
+	var top *topTalkersCollector
+	if cfg.getBool("conntrack_top", false) {
+		top = newTopTalkersCollector()
+		if state != nil {
+			state.Lock()
+			defer state.Unlock()
+			top.last = state.talkers
+		}
+		fns = append(fns, top.add)
+	}
+	var entries *entryCounter
+	if cfg.getBool("conntrack_entries", false) {
+		entries = newEntryCounter()
+		fns = append(fns, entries.add)
+	}
+
+	err := readConntrack(conntrackInfo, func(e *conntrackEntry) {
+		for _, fn := range fns {
+			fn(e)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	ct, ok := stats[conntrackKey].(map[string]interface{})
+	if !ok {
+		ct = map[string]interface{}{}
+		stats[conntrackKey] = ct
+	}
+	if entries != nil {
+		ct["entries"] = entries.stats()
+	}
+	if top != nil {
+		if err := top.store(ct, cfg.getInt("conntrack_top_n", 10)); err != nil {
+			return err
+		}
+		if state != nil {
+			state.talkers = top.cur
+		}
+	}
+	return nil
+}
+
+// entryCounter counts conntrack entries by protocol, TCP state, zone and mark,
+// counters are kept behind pointers so counting known keys does not allocate
+type entryCounter struct {
+	total     int64
+	proto     map[string]*int64
+	unreplied map[string]*int64
+	assured   map[string]*int64
+	tcpState  map[string]*int64
+	zone      map[string]*int64
+	mark      map[string]*int64
+}
+
+func newEntryCounter() *entryCounter {
+	c := &entryCounter{
+		proto:     map[string]*int64{},
+		unreplied: map[string]*int64{},
+		assured:   map[string]*int64{},
+		tcpState:  map[string]*int64{},
+		zone:      map[string]*int64{"0": new(int64)},
+		mark:      map[string]*int64{},
+	}
+	for _, p := range l4Protos {
+		c.proto[p] = new(int64)
+		c.unreplied[p] = new(int64)
+		c.assured[p] = new(int64)
+	}
+	for _, s := range tcpStates {
+		c.tcpState[s] = new(int64)
+	}
+	return c
+}
+
+func (c *entryCounter) add(e *conntrackEntry) {
+	c.total++
+	inc(c.proto, e.proto)
+	if e.unreplied {
+		inc(c.unreplied, e.proto)
+	}
+	if e.assured {
+		inc(c.assured, e.proto)
+	}
+	if bytes.Equal(e.proto, ctTCP) && len(e.state) > 0 {
+		inc(c.tcpState, e.state)
+	}
+	// entries of default zone do not have zone field
+	if len(e.zone) == 0 {
+		*c.zone["0"]++
+	} else {
+		inc(c.zone, e.zone)
+	}
+	if len(e.mark) > 0 {
+		inc(c.mark, e.mark)
+	}
+}
+
+// inc increments counter of given key, only new keys are allocated
+func inc(m map[string]*int64, key []byte) {
+	if v, ok := m[string(key)]; ok {
+		*v++
+		return
+	}
+	v := int64(1)
+	m[string(key)] = &v
+}
+
+func (c *entryCounter) stats() map[string]interface{} {
+	proto := map[string]interface{}{}
+	for p, v := range c.proto {
+		proto[p] = map[string]interface{}{
+			"total":     *v,
+			"unreplied": value(c.unreplied[p]),
+			"assured":   value(c.assured[p]),
+		}
+	}
+	return map[string]interface{}{
+		"total":     c.total,
+		"proto":     proto,
+		"tcp_state": values(c.tcpState),
+		"zone":      values(c.zone),
+		"mark":      values(c.mark),
+	}
+}
+
+func value(v *int64) int64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func values(m map[string]*int64) map[string]interface{} {
+	res := map[string]interface{}{}
+	for k, v := range m {
+		res[k] = *v
+	}
+	return res
+}
+
+// topTalkersCollector calculates flows and addresses which transferred
+// the most bytes since previous collection, nothing is ranked without last counters
+type topTalkersCollector struct {
+	last      map[string]counter
+	cur       map[string]counter
+	flows     map[string]*talker
+	srcs      map[string]*talker
+	dsts      map[string]*talker
+	entries   int
+	accounted int
+}
+
+func newTopTalkersCollector() *topTalkersCollector {
+	return &topTalkersCollector{
+		cur:   map[string]counter{},
+		flows: map[string]*talker{},
+		srcs:  map[string]*talker{},
+		dsts:  map[string]*talker{},
+	}
+}
+
+func (t *topTalkersCollector) add(e *conntrackEntry) {
+	t.entries++
+	if !e.acct {
+		return
+	}
+	t.accounted++
+
+	key := string(e.proto) + " " + string(e.src) + ":" + string(e.sport) + " " + string(e.dst) + ":" + string(e.dport) + " " + string(e.zone)
+	c := counter{packets: e.packets, bytes: e.bytes}
+	t.cur[key] = c
+
+	if t.last == nil {
+		return
+	}
+	// counters of new flows are accounted from their start
+	prev, ok := t.last[key]
+	if !ok || prev.bytes > c.bytes || prev.packets > c.packets {
+		prev = counter{}
+	}
+	delta := counter{packets: c.packets - prev.packets, bytes: c.bytes - prev.bytes}
+	if delta.bytes == 0 {
+		return
+	}
+
+	t.flows[key] = &talker{
+		key: key,
+		tags: map[string]string{
+			"proto": string(e.proto),
+			"src":   string(e.src),
+			"dst":   string(e.dst),
+			"sport": string(e.sport),
+			"dport": string(e.dport),
+		},
+		bytes:   delta.bytes,
+		packets: delta.packets,
+	}
+	addTalker(t.srcs, string(e.src), delta)
+	addTalker(t.dsts, string(e.dst), delta)
+}
+
+// store puts top N talkers into conntrack stats, ranks are used as namespace
+// elements to bound cardinality while flows and addresses are described by metric tags
+func (t *topTalkersCollector) store(ct map[string]interface{}, n int) error {
+	if t.entries > 0 && t.accounted == 0 {
+		return fmt.Errorf("Conntrack entries have no byte counters, enable net.netfilter.nf_conntrack_acct")
+	}
+
+	ct["top_flows"] = topTalkers(t.flows, n)
+	ct["top_src"] = topTalkers(t.srcs, n)
+	ct["top_dst"] = topTalkers(t.dsts, n)
+	return nil
+}
+
+func addTalker(m map[string]*talker, ip string, c counter) {
+	t, ok := m[ip]
+	if !ok {
+		t = &talker{key: ip, tags: map[string]string{"ip": ip}}
+		m[ip] = t
+	}
+	t.bytes += c.bytes
+	t.packets += c.packets
+}
+
+// topTalkers returns N talkers with the most bytes indexed by rank starting
+// from 1, all ranks are present so metric catalog does not depend on traffic
+func topTalkers(m map[string]*talker, n int) map[string]interface{} {
+	sorted := make([]*talker, 0, len(m))
+	for _, t := range m {
+		sorted = append(sorted, t)
+	}
+	sort.Sort(byBytes(sorted))
+
+	top := map[string]interface{}{}
+	for i := 0; i < n; i++ {
+		t := &talker{tags: map[string]string{}}
+		if i < len(sorted) {
+			t = sorted[i]
+		}
+		top[strconv.Itoa(i+1)] = map[string]interface{}{
+			"bytes":   taggedValue{value: int64(t.bytes), tags: t.tags},
+			"packets": taggedValue{value: int64(t.packets), tags: t.tags},
+		}
+	}
+	return top
+}
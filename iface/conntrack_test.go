@@ -0,0 +1,241 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestParseConntrackLine(t *testing.T) {
+	Convey("Given conntrack entry of tcp connection", t, func() {
+		line := []byte("ipv4     2 tcp      6 102 TIME_WAIT src=10.0.0.7 dst=10.0.1.20 sport=33100 dport=80 packets=10 bytes=900 " +
+			"src=10.0.1.20 dst=10.0.0.7 sport=80 dport=33100 packets=8 bytes=5100 [ASSURED] mark=3 zone=2 use=2\n")
+
+		Convey("When it is parsed", func() {
+			e := &conntrackEntry{}
+			err := parseConntrackLine(line, e)
+
+			Convey("Original direction and summed counters are returned", func() {
+				So(err, ShouldBeNil)
+				So(string(e.l3), ShouldEqual, "ipv4")
+				So(string(e.proto), ShouldEqual, "tcp")
+				So(string(e.state), ShouldEqual, "TIME_WAIT")
+				So(string(e.src), ShouldEqual, "10.0.0.7")
+				So(string(e.dst), ShouldEqual, "10.0.1.20")
+				So(string(e.sport), ShouldEqual, "33100")
+				So(string(e.dport), ShouldEqual, "80")
+				So(e.packets, ShouldEqual, 18)
+				So(e.bytes, ShouldEqual, 6000)
+				So(e.acct, ShouldBeTrue)
+				So(e.assured, ShouldBeTrue)
+				So(e.unreplied, ShouldBeFalse)
+				So(string(e.mark), ShouldEqual, "3")
+				So(string(e.zone), ShouldEqual, "2")
+			})
+		})
+
+		Convey("When truncated entry is parsed", func() {
+			err := parseConntrackLine([]byte("ipv4 2 tcp\n"), &conntrackEntry{})
+
+			Convey("Error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestGetConntrackEntries(t *testing.T) {
+	Convey("Given conntrack dump", t, func() {
+		conntrackInfo = "../examples/test/proc.net.nf_conntrack"
+		defer func() { conntrackInfo = "/proc/net/nf_conntrack" }()
+		cfg := config{"conntrack_entries": ctypes.ConfigValueBool{Value: true}}
+		entries := func(stats map[string]interface{}, ns ...string) interface{} {
+			return getMapValueByNamespace(stats, append([]string{conntrackKey, "entries"}, ns...))
+		}
+
+		Convey("When entries are counted", func() {
+			stats := map[string]interface{}{}
+			err := getConntrackStats(stats, cfg, nil)
+			So(err, ShouldBeNil)
+
+			Convey("They are broken down by protocol", func() {
+				So(entries(stats, "total"), ShouldEqual, 7)
+				So(entries(stats, "proto", "tcp", "total"), ShouldEqual, 4)
+				So(entries(stats, "proto", "tcp", "assured"), ShouldEqual, 4)
+				So(entries(stats, "proto", "udp", "total"), ShouldEqual, 2)
+				So(entries(stats, "proto", "udp", "unreplied"), ShouldEqual, 1)
+				So(entries(stats, "proto", "icmp", "total"), ShouldEqual, 1)
+				So(entries(stats, "proto", "icmpv6", "total"), ShouldEqual, 0)
+			})
+
+			Convey("TCP entries are broken down by state", func() {
+				So(entries(stats, "tcp_state", "ESTABLISHED"), ShouldEqual, 3)
+				So(entries(stats, "tcp_state", "TIME_WAIT"), ShouldEqual, 1)
+				So(entries(stats, "tcp_state", "SYN_SENT"), ShouldEqual, 0)
+			})
+
+			Convey("They are broken down by zone and mark", func() {
+				So(entries(stats, "zone", "0"), ShouldEqual, 6)
+				So(entries(stats, "zone", "1"), ShouldEqual, 1)
+				So(entries(stats, "mark", "0"), ShouldEqual, 6)
+				So(entries(stats, "mark", "1"), ShouldEqual, 1)
+			})
+
+			Convey("Top talkers are not calculated", func() {
+				So(getMapValueByNamespace(stats, []string{conntrackKey, "top_flows"}), ShouldBeNil)
+			})
+		})
+
+		Convey("When accounting is disabled", func() {
+			conntrackInfo = "../examples/test/proc.net.nf_conntrack.noacct"
+			stats := map[string]interface{}{}
+			err := getConntrackStats(stats, cfg, nil)
+
+			Convey("Entries are still counted", func() {
+				So(err, ShouldBeNil)
+				So(entries(stats, "total"), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func BenchmarkGetConntrackEntries(b *testing.B) {
+	fh, err := ioutil.TempFile("", "nf_conntrack")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(fh.Name())
+	w := bufio.NewWriter(fh)
+	for i := 0; i < 1000000; i++ {
+		fmt.Fprintf(w, "ipv4     2 udp      17 25 src=10.0.%d.%d dst=10.1.0.1 sport=%d dport=53 [UNREPLIED] src=10.1.0.1 dst=10.0.%d.%d sport=53 dport=%d mark=%d zone=0 use=2\n",
+			i/256%256, i%256, 1024+i%60000, i/256%256, i%256, 1024+i%60000, i%4)
+	}
+	w.Flush()
+	fh.Close()
+
+	conntrackInfo = fh.Name()
+	defer func() { conntrackInfo = "/proc/net/nf_conntrack" }()
+	cfg := config{"conntrack_entries": ctypes.ConfigValueBool{Value: true}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := getConntrackStats(map[string]interface{}{}, cfg, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGetTopTalkersStats(t *testing.T) {
+	Convey("Given conntrack dumps with accounting enabled", t, func() {
+		defer func() { conntrackInfo = "/proc/net/nf_conntrack" }()
+		cfg := config{
+			"conntrack_top":   ctypes.ConfigValueBool{Value: true},
+			"conntrack_top_n": ctypes.ConfigValueInt{Value: 3},
+		}
+		rank := func(stats map[string]interface{}, group, r, stat string) taggedValue {
+			return getMapValueByNamespace(stats, []string{conntrackKey, group, r, stat}).(taggedValue)
+		}
+		state := &sourceState{}
+
+		Convey("When the first dump is read", func() {
+			conntrackInfo = "../examples/test/proc.net.nf_conntrack"
+			stats := map[string]interface{}{}
+			err := getConntrackStats(stats, cfg, state)
+			So(err, ShouldBeNil)
+
+			Convey("All ranks are empty as there is no previous dump", func() {
+				for _, group := range []string{"top_flows", "top_src", "top_dst"} {
+					for _, r := range []string{"1", "2", "3"} {
+						So(rank(stats, group, r, "bytes").value, ShouldEqual, 0)
+						So(rank(stats, group, r, "bytes").tags, ShouldBeEmpty)
+					}
+				}
+				So(getMapValueByNamespace(stats, []string{conntrackKey, "top_flows", "4"}), ShouldBeNil)
+			})
+
+			Convey("When the next dump is read", func() {
+				conntrackInfo = "../examples/test/proc.net.nf_conntrack.2"
+				stats := map[string]interface{}{}
+				err := getConntrackStats(stats, cfg, state)
+				So(err, ShouldBeNil)
+
+				Convey("Flows are ranked by bytes transferred within interval", func() {
+					first := rank(stats, "top_flows", "1", "bytes")
+					So(first.value, ShouldEqual, 500000)
+					So(first.tags, ShouldResemble, map[string]string{
+						"proto": "tcp", "src": "10.0.0.5", "dst": "10.0.1.21", "sport": "40022", "dport": "22",
+					})
+					So(rank(stats, "top_flows", "2", "bytes").value, ShouldEqual, 15000)
+					So(rank(stats, "top_flows", "2", "packets").value, ShouldEqual, 20)
+					So(rank(stats, "top_flows", "3", "bytes").value, ShouldEqual, 60)
+					So(rank(stats, "top_dst", "1", "bytes").tags["ip"], ShouldEqual, "10.0.1.21")
+				})
+
+				Convey("Idle flows leave ranks empty", func() {
+					third := rank(stats, "top_src", "3", "bytes")
+					So(third.value, ShouldEqual, 0)
+					So(third.tags, ShouldBeEmpty)
+				})
+			})
+
+			Convey("When dump is read for catalog between collections", func() {
+				conntrackInfo = "../examples/test/proc.net.nf_conntrack.2"
+				So(getConntrackStats(map[string]interface{}{}, cfg, nil), ShouldBeNil)
+
+				Convey("Baseline of the next collection is not advanced", func() {
+					stats := map[string]interface{}{}
+					So(getConntrackStats(stats, cfg, state), ShouldBeNil)
+					So(rank(stats, "top_flows", "1", "bytes").value, ShouldEqual, 500000)
+				})
+			})
+		})
+
+		Convey("When dumps are read by another plugin instance", func() {
+			conntrackInfo = "../examples/test/proc.net.nf_conntrack"
+			So(getConntrackStats(map[string]interface{}{}, cfg, &sourceState{}), ShouldBeNil)
+
+			Convey("Its baseline is not shared", func() {
+				stats := map[string]interface{}{}
+				So(getConntrackStats(stats, cfg, state), ShouldBeNil)
+				So(rank(stats, "top_flows", "1", "bytes").value, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When accounting is disabled", func() {
+			conntrackInfo = "../examples/test/proc.net.nf_conntrack.noacct"
+			err := getConntrackStats(map[string]interface{}{}, cfg, state)
+
+			Convey("Error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
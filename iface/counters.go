@@ -0,0 +1,162 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	// statsBackendProcfs reads interface statistics of /proc/net/dev
+	statsBackendProcfs = "procfs"
+	// statsBackendSysfs reads statistics directories of /sys/class/net
+	statsBackendSysfs = "sysfs"
+	// statsBackendNetlink reads IFLA_STATS64 of rtnetlink link dump
+	statsBackendNetlink = "netlink"
+
+	// iflaStats64 is link attribute holding struct rtnl_link_stats64
+	iflaStats64 = 23
+)
+
+// linkStats64Fields are fields of struct rtnl_link_stats64 in their order,
+// which are named as files of statistics directory in sysfs
+var linkStats64Fields = []string{
+	"rx_packets", "tx_packets", "rx_bytes", "tx_bytes",
+	"rx_errors", "tx_errors", "rx_dropped", "tx_dropped",
+	"multicast", "collisions",
+	"rx_length_errors", "rx_over_errors", "rx_crc_errors", "rx_frame_errors",
+	"rx_fifo_errors", "rx_missed_errors",
+	"tx_aborted_errors", "tx_carrier_errors", "tx_fifo_errors",
+	"tx_heartbeat_errors", "tx_window_errors",
+	"rx_compressed", "tx_compressed",
+}
+
+// netDevColumns are kernel link statistics summed into columns of
+// /proc/net/dev, named as statistics read from it are named
+var netDevColumns = []struct {
+	name   string
+	fields []string
+}{
+	{"bytes_recv", []string{"rx_bytes"}},
+	{"packets_recv", []string{"rx_packets"}},
+	{"errs_recv", []string{"rx_errors"}},
+	{"drop_recv", []string{"rx_dropped", "rx_missed_errors"}},
+	{"fifo_recv", []string{"rx_fifo_errors"}},
+	{"frame_recv", []string{"rx_length_errors", "rx_over_errors", "rx_crc_errors", "rx_frame_errors"}},
+	{"compressed_recv", []string{"rx_compressed"}},
+	{"multicast_recv", []string{"multicast"}},
+	{"bytes_sent", []string{"tx_bytes"}},
+	{"packets_sent", []string{"tx_packets"}},
+	{"errs_sent", []string{"tx_errors"}},
+	{"drop_sent", []string{"tx_dropped"}},
+	{"fifo_sent", []string{"tx_fifo_errors"}},
+	// columns of transmit side are named by header of receive side
+	{"frame_sent", []string{"collisions"}},
+	{"compressed_sent", []string{"tx_carrier_errors", "tx_aborted_errors", "tx_window_errors", "tx_heartbeat_errors"}},
+	{"multicast_sent", []string{"tx_compressed"}},
+}
+
+// checkStatsBackend tells whether backend is known
+func checkStatsBackend(backend string) error {
+	switch backend {
+	case statsBackendProcfs, statsBackendSysfs, statsBackendNetlink:
+		return nil
+	}
+	return fmt.Errorf("Unknown stats backend {%s}, expected %s, %s or %s", backend, statsBackendProcfs, statsBackendSysfs, statsBackendNetlink)
+}
+
+// readStats reads statistics of host interfaces from given backend
+func readStats(backend string, stats map[string]interface{}) error {
+	switch backend {
+	case statsBackendSysfs:
+		return readSysfsStats(stats)
+	case statsBackendNetlink:
+		return readNetlinkStats(stats)
+	case statsBackendProcfs:
+		return readNetDev(ifaceInfo, stats)
+	}
+	return checkStatsBackend(backend)
+}
+
+// readSysfsStats reads statistics directories of interfaces in sysfs
+func readSysfsStats(stats map[string]interface{}) error {
+	dirs, err := ioutil.ReadDir(sysClassNet)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		fields := map[string]int64{}
+		for _, f := range linkStats64Fields {
+			fields[f] = readSysfsInt(d.Name(), "statistics/"+f, 10)
+		}
+		if fields["rx_bytes"] < 0 {
+			// not an interface or interface is gone
+			continue
+		}
+		stats[d.Name()] = netDevStats(fields)
+	}
+	return nil
+}
+
+// readNetlinkStats reads IFLA_STATS64 of interfaces over rtnetlink
+func readNetlinkStats(stats map[string]interface{}) error {
+	links, err := getLinks()
+	if err != nil {
+		return err
+	}
+	linkStats(links, stats)
+	return nil
+}
+
+// linkStats stores statistics of IFLA_STATS64 of links
+func linkStats(links []link, stats map[string]interface{}) {
+	for _, l := range links {
+		if len(l.stats64) < 8*len(linkStats64Fields) {
+			continue
+		}
+		fields := map[string]int64{}
+		for i, f := range linkStats64Fields {
+			fields[f] = int64(nativeEndian.Uint64(l.stats64[8*i:]))
+		}
+		stats[l.name] = netDevStats(fields)
+	}
+}
+
+// netDevStats sums kernel link statistics into columns of /proc/net/dev,
+// column is -1 when any of its statistics is not available
+func netDevStats(fields map[string]int64) map[string]interface{} {
+	istats := map[string]interface{}{}
+	for _, c := range netDevColumns {
+		sum := int64(0)
+		for _, f := range c.fields {
+			v := fields[f]
+			if v < 0 {
+				sum = -1
+				break
+			}
+			sum += v
+		}
+		istats[c.name] = sum
+	}
+	return istats
+}
@@ -0,0 +1,84 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatsBackends(t *testing.T) {
+	// p3p1 of fixtures has error counters which are summed into columns
+	expected := map[string]interface{}{
+		"bytes_recv": int64(1412848320), "packets_recv": int64(12238775), "errs_recv": int64(7), "drop_recv": int64(7),
+		"fifo_recv": int64(6), "frame_recv": int64(6), "compressed_recv": int64(0), "multicast_recv": int64(40),
+		"bytes_sent": int64(6434234393), "packets_sent": int64(17015516), "errs_sent": int64(3), "drop_sent": int64(1),
+		"fifo_sent": int64(0), "frame_sent": int64(4), "compressed_sent": int64(4), "multicast_sent": int64(0),
+	}
+
+	Convey("Given sysfs with interface statistics", t, func() {
+		sysClassNet = "../examples/test/sys/class/net"
+		defer func() { sysClassNet = "/sys/class/net" }()
+
+		Convey("When statistics are read from sysfs backend", func() {
+			stats := map[string]interface{}{}
+			So(readStats(statsBackendSysfs, stats), ShouldBeNil)
+
+			Convey("They are named and summed as columns of /proc/net/dev", func() {
+				So(stats, ShouldHaveLength, 1)
+				So(stats["p3p1"], ShouldResemble, expected)
+			})
+		})
+	})
+
+	Convey("Given link with IFLA_STATS64", t, func() {
+		values := map[string]uint64{
+			"rx_packets": 12238775, "tx_packets": 17015516, "rx_bytes": 1412848320, "tx_bytes": 6434234393,
+			"rx_errors": 7, "tx_errors": 3, "rx_dropped": 5, "tx_dropped": 1, "multicast": 40, "collisions": 4,
+			"rx_length_errors": 1, "rx_crc_errors": 2, "rx_frame_errors": 3, "rx_fifo_errors": 6, "rx_missed_errors": 2,
+			"tx_aborted_errors": 1, "tx_carrier_errors": 2, "tx_window_errors": 1,
+		}
+		// kernels append fields, e.g. rx_nohandler, which are ignored
+		b := make([]byte, 8*(len(linkStats64Fields)+1))
+		for i, f := range linkStats64Fields {
+			nativeEndian.PutUint64(b[8*i:], values[f])
+		}
+		links := []link{{name: "p3p1", stats64: b}, {name: "nostats"}}
+
+		Convey("When statistics are read", func() {
+			stats := map[string]interface{}{}
+			linkStats(links, stats)
+
+			Convey("They are the same as of sysfs backend", func() {
+				So(stats, ShouldHaveLength, 1)
+				So(stats["p3p1"], ShouldResemble, expected)
+			})
+		})
+	})
+
+	Convey("Unknown backend is rejected", t, func() {
+		err := readStats("ethtool", map[string]interface{}{})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "Unknown stats backend {ethtool}")
+	})
+}
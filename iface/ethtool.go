@@ -0,0 +1,130 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// ethtool commands and structures, see linux/ethtool.h
+const (
+	siocEthtool = 0x8946
+
+	ethtoolGSet     = 0x1
+	ethtoolGDrvInfo = 0x3
+	ethtoolGStrings = 0x1b
+	ethtoolGStats   = 0x1d
+
+	ethSSStats    = 1
+	ethGStringLen = 32
+
+	// sizeofEthtoolCmd is size of struct ethtool_cmd, speed is split into
+	// 16 bit halves at offsets 12 and 28
+	sizeofEthtoolCmd = 44
+	speedUnknown     = 0xffffffff
+
+	// sizeofDrvInfo is size of struct ethtool_drvinfo, n_stats follows
+	// five 32 byte strings, 12 reserved bytes and n_priv_flags
+	sizeofDrvInfo = 196
+	drvInfoNStats = 180
+)
+
+// ifreqData is struct ifreq with ifr_data member of the union
+type ifreqData struct {
+	name [syscall.IFNAMSIZ]byte
+	data uintptr
+	_    [24 - unsafe.Sizeof(uintptr(0))]byte
+}
+
+// ethtool issues ethtool command stored at the beginning of buf
+func ethtool(fd int, name string, buf []byte) error {
+	ifr := ifreqData{data: uintptr(unsafe.Pointer(&buf[0]))}
+	copy(ifr.name[:syscall.IFNAMSIZ-1], name)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocEthtool, uintptr(unsafe.Pointer(&ifr)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ethtoolSpeed returns link speed in Mb/s, ok is false when it is not known
+func ethtoolSpeed(fd int, name string) (int64, bool) {
+	cmd := make([]byte, sizeofEthtoolCmd)
+	nativeEndian.PutUint32(cmd, ethtoolGSet)
+	if err := ethtool(fd, name, cmd); err != nil {
+		return 0, false
+	}
+	speed := uint32(nativeEndian.Uint16(cmd[28:30]))<<16 | uint32(nativeEndian.Uint16(cmd[12:14]))
+	if speed == 0 || speed == speedUnknown {
+		return 0, false
+	}
+	return int64(speed), true
+}
+
+// ethtoolStats returns driver statistics of interface
+func ethtoolStats(fd int, name string) (map[string]interface{}, error) {
+	info := make([]byte, sizeofDrvInfo)
+	nativeEndian.PutUint32(info, ethtoolGDrvInfo)
+	if err := ethtool(fd, name, info); err != nil {
+		return nil, err
+	}
+	n := int(nativeEndian.Uint32(info[drvInfoNStats:]))
+	if n == 0 {
+		return nil, nil
+	}
+
+	strs := make([]byte, 12+n*ethGStringLen)
+	nativeEndian.PutUint32(strs[0:], ethtoolGStrings)
+	nativeEndian.PutUint32(strs[4:], ethSSStats)
+	nativeEndian.PutUint32(strs[8:], uint32(n))
+	if err := ethtool(fd, name, strs); err != nil {
+		return nil, err
+	}
+	vals := make([]byte, 8+n*8)
+	nativeEndian.PutUint32(vals[0:], ethtoolGStats)
+	nativeEndian.PutUint32(vals[4:], uint32(n))
+	if err := ethtool(fd, name, vals); err != nil {
+		return nil, err
+	}
+
+	// number of statistics may change between calls, only filled ones are used
+	if filled := int(nativeEndian.Uint32(vals[4:])); filled < n {
+		n = filled
+	}
+	stats := map[string]interface{}{}
+	for i := 0; i < n; i++ {
+		stat := nlString(strs[12+i*ethGStringLen : 12+(i+1)*ethGStringLen])
+		stat = strings.Map(func(r rune) rune {
+			if r == '/' || r == ' ' {
+				return '_'
+			}
+			return r
+		}, strings.TrimSpace(stat))
+		if stat == "" {
+			continue
+		}
+		stats[stat] = int64(nativeEndian.Uint64(vals[8+i*8:]))
+	}
+	return stats, nil
+}
@@ -0,0 +1,159 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const (
+	// fdbKey is namespace element under which forwarding database metrics are published
+	fdbKey = "_fdb"
+
+	// ndmsg and neighbor attributes, see linux/neighbour.h
+	ndmsgLen     = 12
+	ndaDst       = 1
+	ndaMaster    = 9
+	ntfSelf      = 0x02
+	nudNoarp     = 0x40
+	nudPermanent = 0x80
+
+	// iflaBrAgeingTime is bridge ageing time in clock ticks
+	iflaBrAgeingTime = 4
+	userHZ           = 100
+)
+
+// fdbEntry is single forwarding database entry
+type fdbEntry struct {
+	ifindex int
+	state   uint16
+	flags   uint8
+	// master is set for entries of bridge forwarding database
+	master bool
+	// remote is set for VXLAN entries pointing to remote endpoint
+	remote bool
+}
+
+// getFDBStats counts forwarding database entries of bridge ports and VXLAN
+// devices and stores them in stats map under fdbKey
+func getFDBStats(stats map[string]interface{}, cfg config, _ *sourceState) error {
+	links, err := getLinks()
+	if err != nil {
+		return err
+	}
+
+	req := make([]byte, ndmsgLen)
+	req[0] = syscall.AF_BRIDGE
+	msgs, err := nlRequest(syscall.NETLINK_ROUTE, syscall.RTM_GETNEIGH, syscall.NLM_F_DUMP, req)
+	if err != nil {
+		return err
+	}
+	entries, err := parseFDB(msgs)
+	if err != nil {
+		return err
+	}
+
+	stats[fdbKey] = fdbStats(links, entries)
+	return nil
+}
+
+// parseFDB decodes RTM_NEWNEIGH messages of AF_BRIDGE family
+func parseFDB(msgs []syscall.NetlinkMessage) ([]fdbEntry, error) {
+	entries := []fdbEntry{}
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH {
+			continue
+		}
+		if len(m.Data) < ndmsgLen {
+			return nil, fmt.Errorf("Truncated neighbor message")
+		}
+		if m.Data[0] != syscall.AF_BRIDGE {
+			continue
+		}
+		attrs, err := attrMap(m.Data[ndmsgLen:])
+		if err != nil {
+			return nil, err
+		}
+		_, master := attrs[ndaMaster]
+		_, remote := attrs[ndaDst]
+		entries = append(entries, fdbEntry{
+			ifindex: int(int32(nativeEndian.Uint32(m.Data[4:8]))),
+			state:   nativeEndian.Uint16(m.Data[8:10]),
+			flags:   m.Data[10],
+			master:  master,
+			remote:  remote,
+		})
+	}
+	return entries, nil
+}
+
+// fdbStats returns entry counts of bridges, bridge ports and VXLAN devices,
+// entries of device address lists are skipped, bridge ports are tagged with bridge name
+func fdbStats(links []link, entries []fdbEntry) map[string]interface{} {
+	byIndex := map[int]link{}
+	counts := map[int]map[string]int64{}
+	for _, l := range links {
+		byIndex[l.index] = l
+		if l.kind == "bridge" || l.kind == "vxlan" || l.slaveKind == "bridge" {
+			counts[l.index] = map[string]int64{"total": 0, "static": 0, "dynamic": 0, "remote": 0}
+		}
+	}
+
+	for _, e := range entries {
+		c, ok := counts[e.ifindex]
+		if !ok {
+			continue
+		}
+		// only VXLAN devices keep their own forwarding entries
+		if !e.master && (e.flags&ntfSelf == 0 || byIndex[e.ifindex].kind != "vxlan") {
+			continue
+		}
+		c["total"]++
+		if e.state&(nudPermanent|nudNoarp) != 0 {
+			c["static"]++
+		} else {
+			c["dynamic"]++
+		}
+		if e.remote {
+			c["remote"]++
+		}
+	}
+
+	fdb := map[string]interface{}{}
+	for index, c := range counts {
+		l := byIndex[index]
+		var tags map[string]string
+		if master, ok := byIndex[l.master]; ok && l.master != 0 {
+			tags = map[string]string{"bridge": master.name}
+		}
+		istats := map[string]interface{}{}
+		for name, v := range c {
+			istats[name] = taggedValue{value: v, tags: tags}
+		}
+		if b := l.data[iflaBrAgeingTime]; l.kind == "bridge" && len(b) == 4 {
+			istats["ageing_time"] = int64(nativeEndian.Uint32(b) / userHZ)
+		}
+		fdb[l.name] = istats
+	}
+	return fdb
+}
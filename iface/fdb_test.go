@@ -0,0 +1,92 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"io/ioutil"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFDBStats(t *testing.T) {
+	Convey("Given recorded dumps of bridge with veth and VXLAN ports", t, func() {
+		links, err := readLinks("../examples/test/rtnl.fdb.links")
+		So(err, ShouldBeNil)
+		b, err := ioutil.ReadFile("../examples/test/rtnl.fdb")
+		So(err, ShouldBeNil)
+		msgs, err := parseNetlink(b)
+		So(err, ShouldBeNil)
+
+		Convey("When forwarding database entries are counted", func() {
+			entries, err := parseFDB(msgs)
+			So(err, ShouldBeNil)
+			stats := fdbStats(links, entries)
+			count := func(iface, name string) taggedValue {
+				return stats[iface].(map[string]interface{})[name].(taggedValue)
+			}
+
+			Convey("Entries of bridge port are split into static and dynamic", func() {
+				So(count("v0", "total").value, ShouldEqual, 5)
+				So(count("v0", "static").value, ShouldEqual, 2)
+				So(count("v0", "dynamic").value, ShouldEqual, 3)
+				So(count("v0", "remote").value, ShouldEqual, 0)
+				So(count("v0", "total").tags, ShouldResemble, map[string]string{"bridge": "br0"})
+			})
+
+			Convey("Entries of VXLAN device include remote ones", func() {
+				So(count("vxlan100", "total").value, ShouldEqual, 4)
+				So(count("vxlan100", "static").value, ShouldEqual, 4)
+				So(count("vxlan100", "remote").value, ShouldEqual, 3)
+			})
+
+			Convey("Bridge ageing time is published", func() {
+				So(count("br0", "total").value, ShouldEqual, 0)
+				So(count("br0", "total").tags, ShouldBeNil)
+				So(stats["br0"].(map[string]interface{})["ageing_time"], ShouldEqual, 300)
+			})
+
+			Convey("Interfaces which are not bridged are skipped", func() {
+				So(stats, ShouldNotContainKey, "v1")
+				So(stats, ShouldNotContainKey, "lo")
+			})
+		})
+	})
+}
+
+func TestGetFDBStatsNetns(t *testing.T) {
+	if !runInNetns(t, "TestGetFDBStatsNetns") {
+		return
+	}
+
+	Convey("Given network namespace without bridges", t, func() {
+		Convey("When forwarding database is dumped", func() {
+			stats := map[string]interface{}{}
+			err := getFDBStats(stats, config{}, nil)
+
+			Convey("No interfaces are published", func() {
+				So(err, ShouldBeNil)
+				So(stats[fdbKey], ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,374 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// firewallKey is namespace element under which firewall rule counters are published
+	firewallKey = "_firewall"
+
+	backendNftables = "nftables"
+	backendIptables = "iptables"
+
+	// nf_tables netlink family, see linux/netfilter/nf_tables.h
+	nfnlSubsysNftables = 10
+	nftMsgNewRule      = 6
+	nftMsgGetRule      = 7
+	nfgenmsgLen        = 4
+
+	nftaRuleTable       = 1
+	nftaRuleChain       = 2
+	nftaRuleHandle      = 3
+	nftaRuleExpressions = 4
+	nftaRuleUserdata    = 7
+	nftaListElem        = 1
+	nftaExprName        = 1
+	nftaExprData        = 2
+	nftaCounterBytes    = 1
+	nftaCounterPackets  = 2
+
+	// nftnlUdataRuleComment is type of rule comment in rule userdata
+	nftnlUdataRuleComment = 0
+)
+
+// nfprotoNames maps netfilter protocol families to names used by nft
+var nfprotoNames = map[uint8]string{
+	1:  "inet",
+	2:  "ip",
+	3:  "arp",
+	5:  "netdev",
+	7:  "bridge",
+	10: "ip6",
+}
+
+// iptablesSave lists commands which print iptables rules with counters per family,
+// hosts without IPv6 tools have no IPv6 rules
+var iptablesSave = []struct {
+	family   string
+	cmd      []string
+	optional bool
+}{
+	{"ip", []string{"iptables-save", "-c"}, false},
+	{"ip6", []string{"ip6tables-save", "-c"}, true},
+}
+
+// ruleCounter holds counters of single firewall rule
+type ruleCounter struct {
+	family  string
+	table   string
+	chain   string
+	rule    string
+	packets int64
+	bytes   int64
+}
+
+// getFirewallStats reads counters of firewall rules and stores them in stats map
+// under firewallKey as <family>/<table>/<chain>/<rule>/{packets,bytes}, rules are
+// named by comment, nftables rules without comment by handle
+func getFirewallStats(stats map[string]interface{}, cfg config, _ *sourceState) error {
+	var rules []ruleCounter
+	var err error
+	switch backend := cfg.getString("firewall_backend", backendNftables); backend {
+	case backendNftables:
+		rules, err = getNftRules()
+	case backendIptables:
+		rules, err = getIptablesRules()
+	default:
+		err = fmt.Errorf("Unknown firewall backend {%s}", backend)
+	}
+	if err != nil {
+		return err
+	}
+
+	fw := map[string]interface{}{}
+	for _, r := range rules {
+		tags := map[string]string{"family": r.family, "table": r.table, "chain": r.chain}
+		ns := []string{r.family, r.table, r.chain, r.rule}
+		for i := range ns {
+			ns[i] = nsElement(ns[i])
+		}
+
+		m := fw
+		for _, n := range ns {
+			next, ok := m[n].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[n] = next
+			}
+			m = next
+		}
+		// rules sharing comment are published together
+		if prev, ok := m["packets"].(taggedValue); ok {
+			r.packets += prev.value.(int64)
+			r.bytes += m["bytes"].(taggedValue).value.(int64)
+		}
+		m["packets"] = taggedValue{value: r.packets, tags: tags}
+		m["bytes"] = taggedValue{value: r.bytes, tags: tags}
+	}
+	stats[firewallKey] = fw
+	return nil
+}
+
+// nsElement replaces characters which cannot be used in namespace element
+func nsElement(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// getNftRules dumps nftables rules over nf_tables netlink family
+func getNftRules() ([]ruleCounter, error) {
+	// nfgenmsg with unspecified family requests rules of all families
+	req := make([]byte, nfgenmsgLen)
+	msgs, err := nlRequest(syscall.NETLINK_NETFILTER, nfnlSubsysNftables<<8|nftMsgGetRule, syscall.NLM_F_DUMP, req)
+	if err != nil {
+		return nil, err
+	}
+	return parseNftRules(msgs)
+}
+
+// parseNftRules returns counters of rules which have counter expression
+func parseNftRules(msgs []syscall.NetlinkMessage) ([]ruleCounter, error) {
+	rules := []ruleCounter{}
+	for _, m := range msgs {
+		if m.Header.Type != nfnlSubsysNftables<<8|nftMsgNewRule {
+			continue
+		}
+		if len(m.Data) < nfgenmsgLen {
+			return nil, fmt.Errorf("Truncated nftables rule message")
+		}
+		attrs, err := attrMap(m.Data[nfgenmsgLen:])
+		if err != nil {
+			return nil, err
+		}
+
+		r := ruleCounter{
+			family: nfprotoNames[m.Data[0]],
+			table:  nlString(attrs[nftaRuleTable]),
+			chain:  nlString(attrs[nftaRuleChain]),
+		}
+		if r.family == "" {
+			r.family = strconv.Itoa(int(m.Data[0]))
+		}
+
+		counter, err := nftCounter(attrs[nftaRuleExpressions])
+		if err != nil {
+			return nil, err
+		}
+		if counter == nil {
+			continue
+		}
+		r.packets, r.bytes = int64(counter.packets), int64(counter.bytes)
+
+		r.rule = nftComment(attrs[nftaRuleUserdata])
+		if r.rule == "" {
+			if len(attrs[nftaRuleHandle]) != 8 {
+				continue
+			}
+			r.rule = "handle_" + strconv.FormatUint(binary.BigEndian.Uint64(attrs[nftaRuleHandle]), 10)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// nftCounter returns first counter among rule expressions, nil if there is none
+func nftCounter(b []byte) (*counter, error) {
+	exprs, err := parseAttrs(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exprs {
+		if e.Type != nftaListElem {
+			continue
+		}
+		expr, err := attrMap(e.Data)
+		if err != nil {
+			return nil, err
+		}
+		if nlString(expr[nftaExprName]) != "counter" {
+			continue
+		}
+		data, err := attrMap(expr[nftaExprData])
+		if err != nil {
+			return nil, err
+		}
+		if len(data[nftaCounterBytes]) != 8 || len(data[nftaCounterPackets]) != 8 {
+			return nil, fmt.Errorf("Wrong nftables counter expression")
+		}
+		return &counter{
+			packets: binary.BigEndian.Uint64(data[nftaCounterPackets]),
+			bytes:   binary.BigEndian.Uint64(data[nftaCounterBytes]),
+		}, nil
+	}
+	return nil, nil
+}
+
+// nftComment returns rule comment kept in type-length-value rule userdata
+func nftComment(b []byte) string {
+	for len(b) >= 2 {
+		t, l := b[0], int(b[1])
+		if 2+l > len(b) {
+			return ""
+		}
+		if t == nftnlUdataRuleComment {
+			return nlString(b[2 : 2+l])
+		}
+		b = b[2+l:]
+	}
+	return ""
+}
+
+// nlString returns netlink string attribute without terminating null byte
+func nlString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// getIptablesRules reads counters of commented iptables rules of all families
+func getIptablesRules() ([]ruleCounter, error) {
+	rules := []ruleCounter{}
+	for _, s := range iptablesSave {
+		out, err := exec.Command(s.cmd[0], s.cmd[1:]...).Output()
+		if e, ok := err.(*exec.Error); ok && e.Err == exec.ErrNotFound && s.optional {
+			log.WithFields(log.Fields{"source": firewallKey}).Warn("Rules of family ", s.family, " are not published, ", err)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Cannot run %s: %v", s.cmd[0], err)
+		}
+		r, err := parseIptablesSave(bytes.NewReader(out), s.family)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r...)
+	}
+	return rules, nil
+}
+
+// parseIptablesSave parses output of iptables-save -c, only rules
+// with comment are returned as iptables rules do not have handles, e.g.
+// [10:600] -A INPUT -s 10.0.0.0/8 -m comment --comment "drop private" -j DROP
+func parseIptablesSave(r io.Reader, family string) ([]ruleCounter, error) {
+	rules := []ruleCounter{}
+	table := ""
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = line[1:]
+			continue
+		case !strings.HasPrefix(line, "["):
+			continue
+		}
+
+		end := strings.Index(line, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("Wrong iptables rule format {%s}", line)
+		}
+		counters := strings.Split(line[1:end], ":")
+		if len(counters) != 2 {
+			return nil, fmt.Errorf("Wrong iptables rule counters {%s}", line)
+		}
+		packets, err := strconv.ParseInt(counters[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		octets, err := strconv.ParseInt(counters[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		fields := strings.Fields(line[end+1:])
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+		comment := iptablesComment(line[end+1:])
+		if comment == "" {
+			continue
+		}
+
+		rules = append(rules, ruleCounter{
+			family:  family,
+			table:   table,
+			chain:   fields[1],
+			rule:    comment,
+			packets: packets,
+			bytes:   octets,
+		})
+	}
+	return rules, scanner.Err()
+}
+
+// iptablesComment returns value of --comment option, quoted value
+// may contain spaces and escaped quotes
+func iptablesComment(rule string) string {
+	const opt = "--comment "
+	i := strings.Index(rule, opt)
+	if i < 0 {
+		return ""
+	}
+	rule = rule[i+len(opt):]
+	if !strings.HasPrefix(rule, "\"") {
+		if j := strings.IndexByte(rule, ' '); j >= 0 {
+			return rule[:j]
+		}
+		return rule
+	}
+
+	comment := []byte{}
+	for j := 1; j < len(rule); j++ {
+		switch rule[j] {
+		case '\\':
+			if j+1 < len(rule) {
+				j++
+				comment = append(comment, rule[j])
+			}
+		case '"':
+			return string(comment)
+		default:
+			comment = append(comment, rule[j])
+		}
+	}
+	return string(comment)
+}
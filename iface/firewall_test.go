@@ -0,0 +1,169 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestParseNftRules(t *testing.T) {
+	Convey("Given recorded nftables rule dump", t, func() {
+		b, err := ioutil.ReadFile("../examples/test/nft.rules")
+		So(err, ShouldBeNil)
+		msgs, err := parseNetlink(b)
+		So(err, ShouldBeNil)
+
+		Convey("When rules are parsed", func() {
+			rules, err := parseNftRules(msgs)
+			So(err, ShouldBeNil)
+
+			Convey("Rules with counter are returned", func() {
+				So(len(rules), ShouldEqual, 5)
+				So(rules[0], ShouldResemble, ruleCounter{
+					family: "inet", table: "filter", chain: "input", rule: "drop invalid", packets: 42, bytes: 2520,
+				})
+				So(rules[3].family, ShouldEqual, "ip6")
+				So(rules[3].rule, ShouldEqual, "drop/bogons")
+			})
+
+			Convey("Rules without comment are named by handle", func() {
+				So(rules[1].rule, ShouldEqual, "handle_7")
+				So(rules[1].packets, ShouldEqual, 1000)
+				So(rules[1].bytes, ShouldEqual, 64000)
+			})
+		})
+	})
+}
+
+func TestParseIptablesSave(t *testing.T) {
+	Convey("Given iptables-save output with counters", t, func() {
+		fh, err := os.Open("../examples/test/iptables-save")
+		So(err, ShouldBeNil)
+		defer fh.Close()
+
+		Convey("When it is parsed", func() {
+			rules, err := parseIptablesSave(fh, "ip")
+			So(err, ShouldBeNil)
+
+			Convey("Commented rules are returned", func() {
+				So(len(rules), ShouldEqual, 4)
+				So(rules[0], ShouldResemble, ruleCounter{
+					family: "ip", table: "nat", chain: "POSTROUTING", rule: "masquerade", packets: 17, bytes: 1020,
+				})
+				So(rules[1].rule, ShouldEqual, "drop invalid")
+				So(rules[2].rule, ShouldEqual, `drop "private" on wan`)
+				So(rules[2].chain, ShouldEqual, "INPUT")
+			})
+		})
+	})
+}
+
+func TestGetFirewallStats(t *testing.T) {
+	Convey("Given iptables backend", t, func() {
+		saved := iptablesSave
+		defer func() { iptablesSave = saved }()
+		iptablesSave = iptablesSave[:0:0]
+		iptablesSave = append(iptablesSave, saved[0])
+		iptablesSave[0].cmd = []string{"cat", "../examples/test/iptables-save"}
+		cfg := config{"firewall_backend": ctypes.ConfigValueStr{Value: backendIptables}}
+
+		Convey("When rule counters are collected", func() {
+			stats := map[string]interface{}{}
+			err := getFirewallStats(stats, cfg, nil)
+			So(err, ShouldBeNil)
+			rule := func(ns ...string) taggedValue {
+				return getMapValueByNamespace(stats, append([]string{firewallKey, "ip"}, ns...)).(taggedValue)
+			}
+
+			Convey("They are published per table and chain", func() {
+				v := rule("filter", "INPUT", "drop_invalid", "packets")
+				So(v.value, ShouldEqual, 120)
+				So(v.tags, ShouldResemble, map[string]string{"family": "ip", "table": "filter", "chain": "INPUT"})
+				So(rule("nat", "POSTROUTING", "masquerade", "bytes").value, ShouldEqual, 1020)
+			})
+
+			Convey("Rules sharing comment are summed", func() {
+				So(rule("filter", "INPUT", "drop__private__on_wan", "packets").value, ShouldEqual, 100)
+				So(rule("filter", "INPUT", "drop__private__on_wan", "bytes").value, ShouldEqual, 6240)
+			})
+		})
+	})
+
+	Convey("Given iptables backend on host without ip6tables-save", t, func() {
+		saved := iptablesSave
+		defer func() { iptablesSave = saved }()
+		iptablesSave = append(iptablesSave[:0:0], saved...)
+		iptablesSave[0].cmd = []string{"cat", "../examples/test/iptables-save"}
+		iptablesSave[1].cmd = []string{"ip6tables-save-missing", "-c"}
+		cfg := config{"firewall_backend": ctypes.ConfigValueStr{Value: backendIptables}}
+
+		Convey("When rule counters are collected", func() {
+			stats := map[string]interface{}{}
+			err := getFirewallStats(stats, cfg, nil)
+
+			Convey("IPv4 rules are published without IPv6 ones", func() {
+				So(err, ShouldBeNil)
+				So(getMapValueByNamespace(stats, []string{firewallKey, "ip", "nat", "POSTROUTING", "masquerade", "bytes"}), ShouldNotBeNil)
+				So(getMapValueByNamespace(stats, []string{firewallKey, "ip6"}), ShouldBeNil)
+			})
+		})
+
+		Convey("When iptables-save is missing too", func() {
+			iptablesSave[0].cmd = []string{"iptables-save-missing", "-c"}
+
+			Convey("Error is reported", func() {
+				So(getFirewallStats(map[string]interface{}{}, cfg, nil), ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given unknown backend", t, func() {
+		cfg := config{"firewall_backend": ctypes.ConfigValueStr{Value: "ipchains"}}
+
+		Convey("Error is reported", func() {
+			So(getFirewallStats(map[string]interface{}{}, cfg, nil), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGetNftRulesNetns(t *testing.T) {
+	if !runInNetns(t, "TestGetNftRulesNetns") {
+		return
+	}
+
+	Convey("Given network namespace without nftables rules", t, func() {
+		Convey("When rules are dumped", func() {
+			rules, err := getNftRules()
+
+			Convey("No rules are returned", func() {
+				So(err, ShouldBeNil)
+				So(rules, ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,257 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// overflowKey is namespace element under which folded interfaces
+	// and elements of optional sources are published
+	overflowKey = "_overflow"
+)
+
+// elementLevels are levels of optional sources which children are open-ended
+// elements, e.g. ports or marks, "*" matches any name; other levels hold
+// statistics of an element or fixed enumerations, e.g. tcp states or ranks
+// of top talkers, and are never folded
+var elementLevels = [][]string{
+	{tcpKey, aggregateByPort},
+	{tcpKey, aggregateBySubnet},
+	{sampleKey},
+	{sampleKey, "*", "*", "port"},
+	{conntrackKey, "entries", "zone"},
+	{conntrackKey, "entries", "mark"},
+	{firewallKey, "*", "*"},
+	{firewallKey, "*", "*", "*"},
+	{fdbKey},
+	{vrfKey},
+}
+
+// applyGuard bounds number of published series, interfaces beyond max_interfaces
+// and elements beyond max_elements at element levels of optional sources are folded
+// into overflow aggregates, interfaces kept in previous collections are kept first;
+// interfaces of host and of procfs roots and network namespaces count together
+func (iface *ifacePlugin) applyGuard(cfg config, named []namedStats) {
+	delete(iface.stats, overflowKey)
+	maxIfaces := cfg.getInt("max_interfaces", 0)
+	maxElements := cfg.getInt("max_elements", 0)
+	if maxIfaces <= 0 && maxElements <= 0 {
+		return
+	}
+
+	overflow := map[string]interface{}{}
+	if maxIfaces > 0 {
+		if iface.kept == nil {
+			iface.kept = map[string]bool{}
+		}
+		all := []map[string]interface{}{iface.stats}
+		for _, n := range named {
+			all = append(all, n.stats)
+		}
+		folded := foldInterfaces(all, overflow, iface.kept, maxIfaces)
+		overflow["interfaces"] = int64(folded)
+		if folded > 0 {
+			log.WithFields(log.Fields{"folded": folded, "max_interfaces": maxIfaces}).Warn("Number of interfaces exceeds limit, folding them into ", overflowKey)
+		}
+	}
+	if maxElements > 0 {
+		folded := 0
+		for key, s := range iface.stats {
+			if m, ok := s.(map[string]interface{}); ok && strings.HasPrefix(key, "_") && key != availabilityKey {
+				folded += foldElements([]string{key}, m, maxElements)
+			}
+		}
+		overflow["elements"] = int64(folded)
+		if folded > 0 {
+			log.WithFields(log.Fields{"folded": folded, "max_elements": maxElements}).Warn("Number of source elements exceeds limit, folding them into ", overflowKey)
+		}
+	}
+	iface.stats[overflowKey] = overflow
+}
+
+// foldInterfaces keeps at most max interface names in all stats and sums
+// statistics of the others into overflow, kept set is updated to interfaces
+// which are kept, number of folded interfaces of all stats is returned
+func foldInterfaces(all []map[string]interface{}, overflow map[string]interface{}, kept map[string]bool, max int) int {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, stats := range all {
+		for name, istats := range stats {
+			if _, ok := istats.(map[string]interface{}); ok && !strings.HasPrefix(name, "_") && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	keep := map[string]bool{}
+	for _, name := range names {
+		if kept[name] && len(keep) < max {
+			keep[name] = true
+		}
+	}
+	for _, name := range names {
+		if !keep[name] && len(keep) < max {
+			keep[name] = true
+		}
+	}
+
+	// overflow has the same statistics as interfaces even if none is folded
+	for _, stats := range all {
+		for _, name := range names {
+			if istats, ok := stats[name].(map[string]interface{}); ok {
+				for stat, val := range istats {
+					if _, ok := val.(int64); ok {
+						overflow[stat] = int64(0)
+					}
+				}
+				break
+			}
+		}
+		if len(overflow) > 0 {
+			break
+		}
+	}
+
+	folded := 0
+	for _, stats := range all {
+		for _, name := range names {
+			istats, ok := stats[name].(map[string]interface{})
+			if keep[name] || !ok {
+				continue
+			}
+			addValues(overflow, istats)
+			delete(stats, name)
+			folded++
+		}
+	}
+
+	for name := range kept {
+		delete(kept, name)
+	}
+	for name := range keep {
+		kept[name] = true
+	}
+	return folded
+}
+
+// foldElements keeps at most max elements at each element level of source
+// statistics under path, elements beyond the first ones in name order are
+// summed into overflow element
+func foldElements(path []string, m map[string]interface{}, max int) int {
+	folded := 0
+	if isElementLevel(path) {
+		folded = foldLevel(m, max)
+	}
+	for name, child := range m {
+		if child, ok := child.(map[string]interface{}); ok && name != overflowKey {
+			folded += foldElements(append(path[:len(path):len(path)], name), child, max)
+		}
+	}
+	return folded
+}
+
+// isElementLevel tells whether path is one of elementLevels
+func isElementLevel(path []string) bool {
+	for _, level := range elementLevels {
+		if len(level) != len(path) {
+			continue
+		}
+		match := true
+		for i := range level {
+			if level[i] != "*" && level[i] != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// foldLevel keeps at most max elements of m, elements beyond the first ones
+// in name order are summed into overflow element
+func foldLevel(m map[string]interface{}, max int) int {
+	folded := 0
+	names := []string{}
+	for name := range m {
+		if name != overflowKey {
+			names = append(names, name)
+		}
+	}
+	if len(names) > max {
+		sort.Strings(names)
+		// elements may be values or maps, both are summed by addValues
+		overflow := map[string]interface{}{}
+		if val, ok := m[overflowKey]; ok {
+			overflow[overflowKey] = val
+		}
+		for _, name := range names[max:] {
+			addValues(overflow, map[string]interface{}{overflowKey: m[name]})
+			delete(m, name)
+			folded++
+		}
+		if val, ok := overflow[overflowKey]; ok {
+			m[overflowKey] = val
+		}
+	}
+	return folded
+}
+
+// addValues adds numeric values of src to dst recursively, unavailable
+// values are skipped and tags of tagged values are dropped
+func addValues(dst, src map[string]interface{}) {
+	for name, val := range src {
+		if tv, ok := val.(taggedValue); ok {
+			val = tv.value
+		}
+		switch val := val.(type) {
+		case int64:
+			if val < 0 {
+				continue
+			}
+			sum, _ := dst[name].(int64)
+			dst[name] = sum + val
+		case float64:
+			if val < 0 {
+				continue
+			}
+			sum, _ := dst[name].(float64)
+			dst[name] = sum + val
+		case map[string]interface{}:
+			sub, ok := dst[name].(map[string]interface{})
+			if !ok {
+				sub = map[string]interface{}{}
+				dst[name] = sub
+			}
+			addValues(sub, val)
+		}
+	}
+}
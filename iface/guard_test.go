@@ -0,0 +1,268 @@
+//go:build unit
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestFoldInterfaces(t *testing.T) {
+	Convey("Given statistics of more interfaces than allowed", t, func() {
+		iface := func(bytes, errs int64) map[string]interface{} {
+			return map[string]interface{}{"bytes_recv": bytes, "errs_recv": errs}
+		}
+		stats := map[string]interface{}{
+			"eth0":      iface(100, 1),
+			"lo":        iface(10, 0),
+			"veth0a1b2": iface(5, -1),
+			"veth3c4d5": iface(7, 2),
+			"_tcp":      map[string]interface{}{},
+		}
+		kept := map[string]bool{"veth3c4d5": true}
+		overflow := map[string]interface{}{}
+
+		Convey("When interfaces are folded", func() {
+			folded := foldInterfaces([]map[string]interface{}{stats}, overflow, kept, 2)
+
+			Convey("Previously kept interfaces are kept first, then in name order", func() {
+				So(folded, ShouldEqual, 2)
+				So(stats, ShouldContainKey, "veth3c4d5")
+				So(stats, ShouldContainKey, "eth0")
+				So(stats, ShouldNotContainKey, "lo")
+				So(stats, ShouldNotContainKey, "veth0a1b2")
+				So(stats, ShouldContainKey, "_tcp")
+				So(kept, ShouldResemble, map[string]bool{"eth0": true, "veth3c4d5": true})
+			})
+
+			Convey("Statistics of folded interfaces are summed", func() {
+				So(overflow, ShouldResemble, map[string]interface{}{"bytes_recv": int64(15), "errs_recv": int64(0)})
+			})
+		})
+	})
+}
+
+func TestFoldElements(t *testing.T) {
+	Convey("Given source statistics with many elements", t, func() {
+		port := func(packets int64) map[string]interface{} {
+			return map[string]interface{}{"packets": taggedValue{value: packets, tags: map[string]string{"port": "x"}}}
+		}
+		src := map[string]interface{}{
+			"port": map[string]interface{}{
+				"22":   port(1),
+				"443":  port(2),
+				"5353": port(3),
+				"80":   port(4),
+			},
+			"entries": map[string]interface{}{
+				"mark": map[string]interface{}{
+					"1": int64(5),
+					"2": int64(6),
+					"3": int64(7),
+					"4": int64(8),
+				},
+				"tcp_state": map[string]interface{}{
+					"CLOSE":       int64(1),
+					"ESTABLISHED": int64(2),
+					"SYN_SENT":    int64(3),
+					"TIME_WAIT":   int64(4),
+				},
+			},
+			"total": int64(10),
+		}
+
+		Convey("When elements of _tcp are folded", func() {
+			folded := foldElements([]string{tcpKey}, src, 3)
+
+			Convey("Elements beyond the limit are summed into overflow element", func() {
+				So(folded, ShouldEqual, 1)
+				ports := src["port"].(map[string]interface{})
+				So(ports, ShouldContainKey, "5353")
+				So(ports, ShouldNotContainKey, "80")
+				So(ports[overflowKey], ShouldResemble, map[string]interface{}{"packets": int64(4)})
+				So(src["total"], ShouldEqual, 10)
+			})
+
+			Convey("Folding again does not change anything", func() {
+				So(foldElements([]string{tcpKey}, src, 3), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When elements of _conntrack are folded", func() {
+			folded := foldElements([]string{conntrackKey}, src, 3)
+
+			Convey("Folded values are summed", func() {
+				So(folded, ShouldEqual, 1)
+				entries := src["entries"].(map[string]interface{})
+				So(entries["mark"], ShouldResemble, map[string]interface{}{"1": int64(5), "2": int64(6), "3": int64(7), overflowKey: int64(8)})
+			})
+
+			Convey("Fixed enumerations are kept", func() {
+				entries := src["entries"].(map[string]interface{})
+				So(entries["tcp_state"], ShouldHaveLength, 4)
+			})
+		})
+	})
+
+	Convey("Given tcp group with more statistics per port than max_elements", t, func() {
+		conns := []tcpConn{}
+		for i, port := range []uint16{22, 443, 80} {
+			conns = append(conns, tcpConn{localPort: port, rtt: uint32(100 * (i + 1)), cwnd: 10, retrans: 1})
+		}
+		stats := map[string]interface{}{
+			tcpKey: aggregateTCPConns(conns, map[uint16]bool{22: true, 443: true, 80: true}, func(tcpConn) tcpAggregation { return tcpAggregation{by: aggregateByPort} }),
+		}
+		iface := &ifacePlugin{stats: stats}
+
+		Convey("When guard is applied", func() {
+			node := cdata.NewNode()
+			node.AddItem("max_elements", ctypes.ConfigValueInt{Value: 2})
+			iface.applyGuard(newConfig(node), nil)
+
+			Convey("Ports are folded but their statistics are kept apart", func() {
+				ports := stats[tcpKey].(map[string]interface{})[aggregateByPort].(map[string]interface{})
+				So(ports, ShouldHaveLength, 3)
+				So(ports, ShouldContainKey, "22")
+				So(ports, ShouldContainKey, "443")
+				So(ports, ShouldNotContainKey, "80")
+				So(ports["22"], ShouldHaveLength, 13)
+				So(ports["22"].(map[string]interface{})["rtt_p99"], ShouldEqual, 100)
+				So(ports[overflowKey].(map[string]interface{})["rtt_p99"], ShouldEqual, 300)
+				So(ports[overflowKey].(map[string]interface{})["cwnd_p50"], ShouldEqual, 10)
+				So(stats[overflowKey].(map[string]interface{})["elements"], ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given sampled rates of many interfaces", t, func() {
+		rate := func(v float64) map[string]interface{} {
+			return map[string]interface{}{"tcp": map[string]interface{}{"packets_rate": v}}
+		}
+		src := map[string]interface{}{"eth0": rate(1.5), "eth1": rate(2.5), "eth2": rate(3.5)}
+
+		Convey("When elements are folded", func() {
+			So(foldElements([]string{sampleKey}, src, 1), ShouldEqual, 2)
+
+			Convey("Rates are summed", func() {
+				So(src[overflowKey], ShouldResemble, rate(6))
+			})
+		})
+	})
+}
+
+func TestGuardCatalog(t *testing.T) {
+	Convey("Given plugin with limited number of interfaces", t, func() {
+		ifaceInfo = "../examples/test/proc.net.dev"
+		defer func() { ifaceInfo = "/proc/net/dev" }()
+		ifacePlg := &ifacePlugin{stats: map[string]interface{}{}}
+
+		node := cdata.NewNode()
+		node.AddItem("max_interfaces", ctypes.ConfigValueInt{Value: 1})
+
+		Convey("When catalog is requested", func() {
+			mts, err := ifacePlg.GetMetricTypes(plugin.PluginConfigType{ConfigDataNode: node})
+			So(err, ShouldBeNil)
+			namespaces := []string{}
+			for _, m := range mts {
+				namespaces = append(namespaces, strings.Join(m.Namespace(), "/"))
+			}
+
+			Convey("Folded interfaces are replaced by overflow aggregate", func() {
+				So(len(mts), ShouldEqual, 33)
+				So(namespaces, ShouldContain, "intel/procfs/iface/lo/bytes_recv")
+				So(namespaces, ShouldNotContain, "intel/procfs/iface/p3p1/bytes_recv")
+				So(namespaces, ShouldContain, "intel/procfs/iface/_overflow/bytes_recv")
+				So(namespaces, ShouldContain, "intel/procfs/iface/_overflow/interfaces")
+			})
+
+			Convey("Number of folded interfaces is collected", func() {
+				mTypes := []plugin.PluginMetricType{
+					{Namespace_: []string{"intel", "procfs", "iface", "_overflow", "interfaces"}, Config_: node},
+					{Namespace_: []string{"intel", "procfs", "iface", "_overflow", "bytes_recv"}, Config_: node},
+				}
+				metrics, err := ifacePlg.CollectMetrics(mTypes)
+				So(err, ShouldBeNil)
+				So(metrics[0].Data_, ShouldEqual, 1)
+				So(metrics[1].Data_, ShouldEqual, 1412848320)
+			})
+		})
+	})
+}
+
+func TestGuardProcRoots(t *testing.T) {
+	Convey("Given plugin with limited number of interfaces and procfs roots", t, func() {
+		ifaceInfo = "../examples/test/proc.net.dev"
+		defer func() { ifaceInfo = "/proc/net/dev" }()
+		ifacePlg := &ifacePlugin{stats: map[string]interface{}{}, host: "hypervisor"}
+
+		node := cdata.NewNode()
+		node.AddItem("max_interfaces", ctypes.ConfigValueInt{Value: 1})
+		node.AddItem("proc_roots", ctypes.ConfigValueStr{Value: "vm1=../examples/test/roots/vm1,sidecar=../examples/test/roots/sidecar"})
+
+		Convey("When catalog is requested", func() {
+			mts, err := ifacePlg.GetMetricTypes(plugin.PluginConfigType{ConfigDataNode: node})
+			So(err, ShouldBeNil)
+			namespaces := []string{}
+			for _, m := range mts {
+				namespaces = append(namespaces, strings.Join(m.Namespace(), "/"))
+			}
+
+			Convey("Interfaces of roots count towards the limit", func() {
+				So(namespaces, ShouldContain, "intel/procfs/iface/eth0/bytes_recv")
+				So(namespaces, ShouldNotContain, "intel/procfs/iface/lo/bytes_recv")
+				So(namespaces, ShouldNotContain, "intel/procfs/iface/p3p1/bytes_recv")
+				So(namespaces, ShouldContain, "intel/procfs/iface/_overflow/bytes_recv")
+			})
+		})
+
+		Convey("When metrics of kept and folded interfaces are collected", func() {
+			mTypes := []plugin.PluginMetricType{
+				{Namespace_: []string{"intel", "procfs", "iface", "eth0", "bytes_recv"}, Config_: node},
+				{Namespace_: []string{"intel", "procfs", "iface", "lo", "bytes_recv"}, Config_: node},
+				{Namespace_: []string{"intel", "procfs", "iface", "_overflow", "interfaces"}, Config_: node},
+				{Namespace_: []string{"intel", "procfs", "iface", "_overflow", "bytes_recv"}, Config_: node},
+			}
+			metrics, err := ifacePlg.CollectMetrics(mTypes)
+			So(err, ShouldBeNil)
+			values := map[string]interface{}{}
+			for _, m := range metrics {
+				values[m.Source_+":"+strings.Join(m.Namespace()[3:], "/")] = m.Data_
+			}
+
+			Convey("Folded interfaces are not published with empty values", func() {
+				So(values, ShouldResemble, map[string]interface{}{
+					"vm1:eth0/bytes_recv":             int64(90210331),
+					"sidecar:eth0/bytes_recv":         int64(523341),
+					"hypervisor:_overflow/interfaces": int64(4),
+					"hypervisor:_overflow/bytes_recv": int64(6419386512),
+				})
+			})
+		})
+	})
+}
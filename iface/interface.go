@@ -28,6 +28,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -45,29 +46,146 @@ const (
 	FS = "procfs"
 	// PLUGIN name namespace part
 	PLUGIN = "iface"
+	// NETNS namespace part of network namespace totals
+	NETNS = "netns"
 	// VERSION of interface info plugin
 	VERSION = 2
 )
 
 var ifaceInfo = "/proc/net/dev"
 
+// now returns time of collection, tests replace it by simulated clock
+var now = time.Now
+
+// source is optional group of metrics published under its own namespace
+// element next to interface names, e.g. /intel/procfs/iface/_tcp/...
+type source struct {
+	// key is namespace element the source metrics are published under
+	key string
+	// stat is set for sources which publish also within namespaces
+	// of interfaces, e.g. /intel/procfs/iface/can0/can/...
+	stat string
+	// enable lists config items, any of which adds source metrics to the catalog
+	enable []string
+	// options lists config items which tune the source when it is enabled
+	options []string
+	// caps lists capabilities without which the source is disabled
+	caps []uint
+	// collect stores source metrics in stats map under key, state is nil
+	// when source is read for catalog or validation
+	collect func(stats map[string]interface{}, cfg config, state *sourceState) error
+}
+
+// sourceState holds what sources keep between collections of plugin instance
+type sourceState struct {
+	sync.Mutex
+	// talkers holds per flow counters of previous collection of conntrack
+	// top talkers, nil before the first collection
+	talkers map[string]counter
+}
+
+var sources = []source{
+	{key: tcpKey, enable: []string{"tcp_info"}, options: []string{"tcp_aggregate", "tcp_prefix_v4", "tcp_prefix_v6"}, collect: getTCPStats},
+	{key: sampleKey, enable: []string{"packet_sample"}, options: []string{"packet_sample_rate", "packet_sample_filter", "packet_sample_ports"}, caps: []uint{capNetRaw}, collect: getSampleStats},
+	{key: conntrackKey, enable: []string{"conntrack_top", "conntrack_entries"}, options: []string{"conntrack_top_n"}, caps: []uint{capNetAdmin}, collect: getConntrackStats},
+	{key: firewallKey, enable: []string{"firewall"}, options: []string{"firewall_backend"}, caps: []uint{capNetAdmin}, collect: getFirewallStats},
+	{key: fdbKey, enable: []string{"fdb"}, collect: getFDBStats},
+	{key: canKey, stat: canStat, enable: []string{"can"}, collect: getCANStats},
+	{key: vrfKey, enable: []string{"vrf"}, collect: getVRFStats},
+}
+
+func (s source) enabled(c config) bool {
+	for _, item := range s.enable {
+		if c.getBool(item, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagSource describes interfaces by tags which are added to their metrics
+type tagSource struct {
+	// enable is config item which enables tags
+	enable string
+	// tags returns tags of links indexed by interface name
+	tags func(links []link) map[string]map[string]string
+}
+
+var tagSources = []tagSource{
+	{enable: "tunnel_tags", tags: eachLink(tunnelTags)},
+	{enable: "vm_tags", tags: vmTags},
+	{enable: "vrf", tags: vrfTags},
+}
+
+// taggedValue is metric value with tags describing what it measures,
+// e.g. flow which has given rank among top talkers
+type taggedValue struct {
+	value interface{}
+	tags  map[string]string
+}
+
 // GetMetricTypes returns list of available metric types
 // It returns error in case retrieval was not successful
-func (iface *ifacePlugin) GetMetricTypes(_ plugin.PluginConfigType) ([]plugin.PluginMetricType, error) {
+func (iface *ifacePlugin) GetMetricTypes(cfg plugin.PluginConfigType) ([]plugin.PluginMetricType, error) {
 	metricTypes := []plugin.PluginMetricType{}
 
-	if err := getStats(iface.stats); err != nil {
+	c := newConfig(cfg.ConfigDataNode)
+	if err := getStats(iface.stats, c.getString("stats_backend", statsBackendProcfs)); err != nil {
+		return nil, err
+	}
+	resolve, err := c.perInterface()
+	if err != nil {
 		return nil, err
 	}
+	details := c.anyInterface("link_details")
+	if details {
+		if err := getLinkDetails(iface.stats); err != nil {
+			log.Warn("Cannot read link details, skipping them in catalog, ", err)
+		}
+	}
+	for _, s := range sources {
+		delete(iface.stats, s.key)
+	}
+	iface.available = map[string]bool{}
+	for _, s := range sources {
+		if s.enabled(c) {
+			iface.collectSource(s, c, nil)
+		}
+	}
+	iface.setAvailability()
+	named, err := iface.readNamedStats(c, details)
+	if err != nil {
+		return nil, err
+	}
+	dropLinkDetails(iface.stats, resolve)
+	for _, n := range named {
+		dropLinkDetails(n.stats, resolve)
+	}
+	iface.applyGuard(c, named)
+
+	catalog := iface.stats
+	if len(named) > 0 {
+		catalog = map[string]interface{}{}
+		for _, n := range append(named, namedStats{stats: iface.stats}) {
+			mergeCatalog(catalog, n.stats)
+		}
+	}
 
 	namespaces := []string{}
 
-	err := ns.FromMap(iface.stats, filepath.Join(VENDOR, FS, PLUGIN), &namespaces)
+	err = ns.FromMap(catalog, filepath.Join(VENDOR, FS, PLUGIN), &namespaces)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if c.getBool("netns_totals", false) {
+		err = ns.FromMap(iface.getNetnsTotals(c), filepath.Join(VENDOR, FS, NETNS), &namespaces)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	for _, namespace := range namespaces {
 		metricType := plugin.PluginMetricType{Namespace_: strings.Split(namespace, string(os.PathSeparator))}
 		metricTypes = append(metricTypes, metricType)
@@ -80,9 +198,66 @@ func (iface *ifacePlugin) GetMetricTypes(_ plugin.PluginConfigType) ([]plugin.Pl
 func (iface *ifacePlugin) CollectMetrics(metricTypes []plugin.PluginMetricType) ([]plugin.PluginMetricType, error) {
 	metrics := []plugin.PluginMetricType{}
 
-	if err := getStats(iface.stats); err != nil {
+	cfg := metricsConfig(metricTypes)
+	if err := getStats(iface.stats, cfg.getString("stats_backend", statsBackendProcfs)); err != nil {
+		return nil, err
+	}
+	metricTypes, totalTypes := splitTotals(metricTypes)
+	if len(totalTypes) > 0 {
+		totals := iface.getNetnsTotals(cfg)
+		for _, metricType := range totalTypes {
+			ns := metricType.Namespace()
+			if len(ns) < 5 {
+				return nil, fmt.Errorf("Namespace length is too short (len = %d)", len(ns))
+			}
+			val := getMapValueByNamespace(totals, ns[3:])
+			if _, ok := val.(map[string]interface{}); ok || val == nil {
+				// namespace is gone
+				continue
+			}
+			// folded namespaces are summed without tags
+			var tags map[string]string
+			if tv, ok := val.(taggedValue); ok {
+				val, tags = tv.value, tv.tags
+			}
+			metrics = append(metrics, plugin.PluginMetricType{
+				Namespace_: ns,
+				Data_:      val,
+				Tags_:      tags,
+				Source_:    iface.host,
+				Timestamp_: now(),
+			})
+		}
+	}
+	configs, err := interfaceConfigs(metricTypes)
+	if err != nil {
 		return nil, err
 	}
+	details := false
+	for _, c := range configs {
+		details = details || c.getBool("link_details", false)
+	}
+	if details {
+		if err := getLinkDetails(iface.stats); err != nil {
+			log.Warn("Cannot read link details, ", err)
+		}
+	}
+
+	iface.collectSources(metricTypes)
+	named, err := iface.readNamedStats(cfg, details)
+	if err != nil {
+		return nil, err
+	}
+	configOf := func(name string) config { return configs[name] }
+	dropLinkDetails(iface.stats, configOf)
+	for _, n := range named {
+		dropLinkDetails(n.stats, configOf)
+	}
+	iface.applyGuard(cfg, named)
+	ifaceTags, err := getIfaceTags(configs)
+	if err != nil {
+		log.Warn("Cannot read interface tags, ", err)
+	}
 
 	for _, metricType := range metricTypes {
 		ns := metricType.Namespace()
@@ -90,13 +265,45 @@ func (iface *ifacePlugin) CollectMetrics(metricTypes []plugin.PluginMetricType)
 			return nil, fmt.Errorf("Namespace length is too short (len = %d)", len(ns))
 		}
 
+		// interface statistics are published also for each procfs root and
+		// network namespace which has the interface, optional sources are
+		// read on host only
+		for _, n := range named {
+			if strings.HasPrefix(ns[3], "_") {
+				break
+			}
+			val := getMapValueByNamespace(n.stats, ns[3:])
+			if val == nil {
+				continue
+			}
+			metrics = append(metrics, plugin.PluginMetricType{
+				Namespace_: ns,
+				Data_:      val,
+				Source_:    n.name,
+				Timestamp_: now(),
+			})
+		}
+
+		// interfaces which are gone or folded by guard are not published
 		val := getMapValueByNamespace(iface.stats, ns[3:])
+		if val == nil {
+			continue
+		}
+
+		var tags map[string]string
+		if tv, ok := val.(taggedValue); ok {
+			val, tags = tv.value, tv.tags
+		}
+		if t, ok := ifaceTags[ns[3]]; ok {
+			tags = mergeTags(tags, t)
+		}
 
 		metric := plugin.PluginMetricType{
 			Namespace_: ns,
 			Data_:      val,
+			Tags_:      tags,
 			Source_:    iface.host,
-			Timestamp_: time.Now(),
+			Timestamp_: now(),
 		}
 		metrics = append(metrics, metric)
 	}
@@ -106,7 +313,233 @@ func (iface *ifacePlugin) CollectMetrics(metricTypes []plugin.PluginMetricType)
 // GetConfigPolicy returns config policy
 // It returns error in case retrieval was not successful
 func (iface *ifacePlugin) GetConfigPolicy() (*cpolicy.ConfigPolicy, error) {
-	return cpolicy.New(), nil
+	c := cpolicy.New()
+	node := cpolicy.NewPolicyNode()
+
+	// items which presets set have no default, snap would fill it in
+	// and the preset could not tell it from an explicit item
+	preset, err := cpolicy.NewStringRule(presetKey, false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tcpInfo, err := cpolicy.NewBoolRule("tcp_info", false)
+	if err != nil {
+		return nil, err
+	}
+	tcpAggregate, err := cpolicy.NewStringRule("tcp_aggregate", false)
+	if err != nil {
+		return nil, err
+	}
+	tcpPrefix4, err := cpolicy.NewIntegerRule("tcp_prefix_v4", false, 24)
+	if err != nil {
+		return nil, err
+	}
+	tcpPrefix4.SetMinimum(0)
+	tcpPrefix4.SetMaximum(32)
+	tcpPrefix6, err := cpolicy.NewIntegerRule("tcp_prefix_v6", false, 64)
+	if err != nil {
+		return nil, err
+	}
+	tcpPrefix6.SetMinimum(0)
+	tcpPrefix6.SetMaximum(128)
+
+	packetSample, err := cpolicy.NewBoolRule("packet_sample", false)
+	if err != nil {
+		return nil, err
+	}
+	packetSampleRate, err := cpolicy.NewIntegerRule("packet_sample_rate", false, 100)
+	if err != nil {
+		return nil, err
+	}
+	packetSampleRate.SetMinimum(1)
+	packetSampleFilter, err := cpolicy.NewStringRule("packet_sample_filter", false, "")
+	if err != nil {
+		return nil, err
+	}
+	packetSamplePorts, err := cpolicy.NewStringRule("packet_sample_ports", false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	conntrackTop, err := cpolicy.NewBoolRule("conntrack_top", false)
+	if err != nil {
+		return nil, err
+	}
+	conntrackTopN, err := cpolicy.NewIntegerRule("conntrack_top_n", false)
+	if err != nil {
+		return nil, err
+	}
+	conntrackTopN.SetMinimum(1)
+	conntrackTopN.SetMaximum(100)
+	conntrackEntries, err := cpolicy.NewBoolRule("conntrack_entries", false)
+	if err != nil {
+		return nil, err
+	}
+
+	firewall, err := cpolicy.NewBoolRule("firewall", false)
+	if err != nil {
+		return nil, err
+	}
+	firewallBackend, err := cpolicy.NewStringRule("firewall_backend", false, backendNftables)
+	if err != nil {
+		return nil, err
+	}
+
+	fdb, err := cpolicy.NewBoolRule("fdb", false)
+	if err != nil {
+		return nil, err
+	}
+	can, err := cpolicy.NewBoolRule("can", false)
+	if err != nil {
+		return nil, err
+	}
+	tunnelTagsRule, err := cpolicy.NewBoolRule("tunnel_tags", false)
+	if err != nil {
+		return nil, err
+	}
+	vmTagsRule, err := cpolicy.NewBoolRule("vm_tags", false)
+	if err != nil {
+		return nil, err
+	}
+	vrf, err := cpolicy.NewBoolRule("vrf", false)
+	if err != nil {
+		return nil, err
+	}
+	procRoots, err := cpolicy.NewStringRule("proc_roots", false, "")
+	if err != nil {
+		return nil, err
+	}
+	netns, err := cpolicy.NewStringRule("netns", false, "")
+	if err != nil {
+		return nil, err
+	}
+	statsBackend, err := cpolicy.NewStringRule("stats_backend", false, statsBackendProcfs)
+	if err != nil {
+		return nil, err
+	}
+	linkDetails, err := cpolicy.NewBoolRule("link_details", false)
+	if err != nil {
+		return nil, err
+	}
+	netnsTotals, err := cpolicy.NewBoolRule("netns_totals", false)
+	if err != nil {
+		return nil, err
+	}
+	maxInterfaces, err := cpolicy.NewIntegerRule("max_interfaces", false)
+	if err != nil {
+		return nil, err
+	}
+	maxInterfaces.SetMinimum(0)
+	maxElements, err := cpolicy.NewIntegerRule("max_elements", false)
+	if err != nil {
+		return nil, err
+	}
+	maxElements.SetMinimum(0)
+
+	node.Add(tcpInfo, tcpAggregate, tcpPrefix4, tcpPrefix6)
+	node.Add(packetSample, packetSampleRate, packetSampleFilter, packetSamplePorts)
+	node.Add(conntrackTop, conntrackTopN, conntrackEntries)
+	node.Add(firewall, firewallBackend)
+	node.Add(fdb, can)
+	node.Add(tunnelTagsRule, vmTagsRule, vrf)
+	node.Add(statsBackend, procRoots, netns, linkDetails, netnsTotals)
+	node.Add(maxInterfaces, maxElements)
+	node.Add(preset)
+	c.Add([]string{VENDOR, FS, PLUGIN}, node)
+	c.Add([]string{VENDOR, FS, NETNS}, node)
+	return c, nil
+}
+
+// splitTotals separates requested network namespace totals from interface metrics
+func splitTotals(metricTypes []plugin.PluginMetricType) (ifaces, totals []plugin.PluginMetricType) {
+	for _, metricType := range metricTypes {
+		if ns := metricType.Namespace(); len(ns) > 2 && ns[2] == NETNS {
+			totals = append(totals, metricType)
+			continue
+		}
+		ifaces = append(ifaces, metricType)
+	}
+	return ifaces, totals
+}
+
+// mergeCatalog adds statistics of src to dst, values of src win
+func mergeCatalog(dst, src map[string]interface{}) {
+	for k, v := range src {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		d, ok := dst[k].(map[string]interface{})
+		if !ok {
+			d = map[string]interface{}{}
+			dst[k] = d
+		}
+		mergeCatalog(d, m)
+	}
+}
+
+// mergeTags returns union of tags, tags of b win
+func mergeTags(a, b map[string]string) map[string]string {
+	tags := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		tags[k] = v
+	}
+	for k, v := range b {
+		tags[k] = v
+	}
+	return tags
+}
+
+// collectSources refreshes optional sources which metrics or availability
+// gauges are requested
+func (iface *ifacePlugin) collectSources(metricTypes []plugin.PluginMetricType) {
+	requested := map[string]bool{}
+	requestedStats := map[string]bool{}
+	for _, metricType := range metricTypes {
+		ns := metricType.Namespace()
+		if len(ns) > 3 {
+			requested[ns[3]] = true
+		}
+		if len(ns) > 4 {
+			requestedStats[ns[4]] = true
+			if ns[3] == availabilityKey {
+				requested["_"+ns[4]] = true
+			}
+		}
+	}
+
+	cfg := metricsConfig(metricTypes)
+	if iface.available == nil {
+		iface.available = map[string]bool{}
+	}
+	for _, s := range sources {
+		if !requested[s.key] && (s.stat == "" || !requestedStats[s.stat]) {
+			continue
+		}
+		if iface.state == nil {
+			iface.state = &sourceState{}
+		}
+		iface.collectSource(s, cfg, iface.state)
+	}
+	iface.setAvailability()
+}
+
+// collectSource refreshes source unless it is disabled for missing capabilities,
+// failure does not fail collection but is reported by availability gauge
+func (iface *ifacePlugin) collectSource(s source, cfg config, state *sourceState) {
+	if _, ok := iface.disabled[s.key]; ok {
+		delete(iface.stats, s.key)
+		iface.available[s.key] = false
+		return
+	}
+	err := s.collect(iface.stats, cfg, state)
+	if err != nil {
+		log.WithFields(log.Fields{"source": s.key}).Warn("Cannot collect source metrics, ", err)
+		delete(iface.stats, s.key)
+	}
+	iface.available[s.key] = err == nil
 }
 
 // New creates instance of interface info plugin
@@ -125,12 +558,36 @@ func New() *ifacePlugin {
 
 	iface := &ifacePlugin{stats: map[string]interface{}{}, host: host}
 
+	caps, err := effectiveCaps(procStatus)
+	if err != nil {
+		log.Warn("Cannot read capabilities of the plugin, all sources are enabled, ", err)
+		return iface
+	}
+	iface.disabled = disabledSources(caps)
+	if len(iface.disabled) > 0 {
+		missing := []string{}
+		for _, s := range privileged() {
+			if names, ok := iface.disabled[s.key]; ok {
+				missing = append(missing, s.key+" needs "+strings.Join(names, "+"))
+			}
+		}
+		log.Warn("Sources are disabled for missing capabilities, ", strings.Join(missing, ", "))
+	}
 	return iface
 }
 
 type ifacePlugin struct {
 	stats map[string]interface{}
 	host  string
+	// kept holds interfaces published in last collection when number
+	// of interfaces is limited
+	kept map[string]bool
+	// disabled holds capabilities missing for sources, indexed by source key
+	disabled map[string][]string
+	// available tells whether last collection of source succeeded
+	available map[string]bool
+	// state is kept by sources between collections
+	state *sourceState
 }
 
 func parseHeader(line string) ([]string, error) {
@@ -167,9 +624,28 @@ func parseHeader(line string) ([]string, error) {
 	return append(recv, sent...), nil
 }
 
-func getStats(stats map[string]interface{}) error {
+// getStats reads statistics of host interfaces from given backend, interfaces
+// which disappeared since previous call are removed from stats
+func getStats(stats map[string]interface{}, backend string) error {
+	current := map[string]interface{}{}
+	if err := readStats(backend, current); err != nil {
+		return err
+	}
+	for name := range stats {
+		if _, ok := current[name]; !ok && !strings.HasPrefix(name, "_") {
+			delete(stats, name)
+		}
+	}
+	for name, istats := range current {
+		stats[name] = istats
+	}
+	return nil
+}
+
+// readNetDev reads interface statistics of given /proc/net/dev file
+func readNetDev(path string, stats map[string]interface{}) error {
 
-	content, err := ioutil.ReadFile(ifaceInfo)
+	content, err := ioutil.ReadFile(path)
 
 	if err != nil {
 		return err
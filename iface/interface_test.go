@@ -59,7 +59,7 @@ func (iis *ifaceInfoSuite) TestGetStats() {
 		})
 
 		Convey("When reading interface statistics from file", func() {
-			err := getStats(stats)
+			err := getStats(stats, statsBackendProcfs)
 
 			Convey("No error should be reported", func() {
 				So(err, ShouldBeNil)
@@ -213,3 +213,40 @@ func createMockIfaceInfo() error {
 func removeIfaceLoadInfo() {
 	os.Remove(ifaceInfo)
 }
+
+func TestGetStatsVanishedInterfaces(t *testing.T) {
+	Convey("Given statistics read while two interfaces were present", t, func() {
+		content, err := ioutil.ReadFile("../examples/test/proc.net.dev")
+		So(err, ShouldBeNil)
+		f, err := ioutil.TempFile("", "net.dev")
+		So(err, ShouldBeNil)
+		defer os.Remove(f.Name())
+		So(ioutil.WriteFile(f.Name(), content, 0644), ShouldBeNil)
+		ifaceInfo = f.Name()
+		defer func() { ifaceInfo = "/proc/net/dev" }()
+
+		stats := map[string]interface{}{}
+		So(getStats(stats, statsBackendProcfs), ShouldBeNil)
+		stats[tcpKey] = map[string]interface{}{"total": int64(1)}
+
+		Convey("When one of them disappears", func() {
+			lines := []string{}
+			for _, line := range strings.Split(string(content), "\n") {
+				if !strings.HasPrefix(strings.TrimSpace(line), "p3p1:") {
+					lines = append(lines, line)
+				}
+			}
+			So(ioutil.WriteFile(f.Name(), []byte(strings.Join(lines, "\n")), 0644), ShouldBeNil)
+			So(getStats(stats, statsBackendProcfs), ShouldBeNil)
+
+			Convey("Its statistics are removed", func() {
+				So(stats, ShouldNotContainKey, "p3p1")
+				So(stats, ShouldContainKey, "lo")
+			})
+
+			Convey("Statistics of sources are kept", func() {
+				So(stats, ShouldContainKey, tcpKey)
+			})
+		})
+	})
+}
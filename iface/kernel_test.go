@@ -0,0 +1,379 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+const (
+	// vethInfoPeer is peer of veth pair, see linux/veth.h
+	vethInfoPeer = 1
+	// iflaVlanID is VLAN ID of vlan interface, see linux/if_link.h
+	iflaVlanID = 1
+	// iflaBrMcastSnooping enables multicast snooping of bridge
+	iflaBrMcastSnooping = 23
+	// iflaOperstate is RFC 2863 operational state of link
+	iflaOperstate = 16
+	ifOperUp      = 6
+	// ethPLocal is ethertype of frames sent by tests, local experimental
+	ethPLocal = 0x88b5
+)
+
+// encodeAttr returns netlink attribute of given type
+func encodeAttr(t uint16, data []byte) []byte {
+	b := make([]byte, nlaAlign(nlaHdrLen+len(data)))
+	nativeEndian.PutUint16(b[0:2], uint16(nlaHdrLen+len(data)))
+	nativeEndian.PutUint16(b[2:4], t)
+	copy(b[nlaHdrLen:], data)
+	return b
+}
+
+func encodeU32(v uint32) []byte {
+	b := make([]byte, 4)
+	nativeEndian.PutUint32(b, v)
+	return b
+}
+
+// ifinfomsg returns link message header of interface of given index
+func ifinfomsg(index int) []byte {
+	b := make([]byte, syscall.SizeofIfInfomsg)
+	nativeEndian.PutUint32(b[4:8], uint32(index))
+	return b
+}
+
+// newLink creates interface of given kind, attrs are added to link
+// attributes and data to kind specific attributes
+func newLink(name, kind string, attrs, data []byte) error {
+	info := encodeAttr(iflaInfoKind, []byte(kind))
+	if data != nil {
+		info = append(info, encodeAttr(iflaInfoData, data)...)
+	}
+	req := ifinfomsg(0)
+	req = append(req, encodeAttr(syscall.IFLA_IFNAME, append([]byte(name), 0))...)
+	req = append(req, encodeAttr(syscall.IFLA_LINKINFO, info)...)
+	req = append(req, attrs...)
+	_, err := nlRequest(syscall.NETLINK_ROUTE, syscall.RTM_NEWLINK, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL|syscall.NLM_F_ACK, req)
+	return err
+}
+
+// setLinkMaster enslaves interface to master interface, e.g. to bridge
+func setLinkMaster(name, master string) error {
+	i, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	m, err := net.InterfaceByName(master)
+	if err != nil {
+		return err
+	}
+	req := append(ifinfomsg(i.Index), encodeAttr(syscall.IFLA_MASTER, encodeU32(uint32(m.Index)))...)
+	_, err = nlRequest(syscall.NETLINK_ROUTE, syscall.RTM_NEWLINK, syscall.NLM_F_ACK, req)
+	return err
+}
+
+// rtnlAttrs returns attributes of interface reported by rtnetlink
+func rtnlAttrs(name string) (map[uint16][]byte, error) {
+	i, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	links, err := nlRequest(syscall.NETLINK_ROUTE, syscall.RTM_GETLINK, 0, ifinfomsg(i.Index))
+	if err != nil {
+		return nil, err
+	}
+	if len(links) != 1 || len(links[0].Data) < syscall.SizeofIfInfomsg {
+		return nil, syscall.EINVAL
+	}
+	return attrMap(links[0].Data[syscall.SizeofIfInfomsg:])
+}
+
+// waitOperUp waits until interfaces are operationally up, bridge is up
+// when any of its ports is forwarding
+func waitOperUp(names []string, timeout time.Duration) error {
+	for deadline := time.Now().Add(timeout); ; time.Sleep(10 * time.Millisecond) {
+		up := true
+		for _, name := range names {
+			attrs, err := rtnlAttrs(name)
+			if err != nil {
+				return err
+			}
+			if b := attrs[iflaOperstate]; len(b) != 1 || b[0] != ifOperUp {
+				up = false
+			}
+		}
+		if up {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Interfaces %v are not up in %v", names, timeout)
+		}
+	}
+}
+
+// mountSysfs mounts sysfs of current network namespace into temporary
+// directory, it requires own mount namespace
+func mountSysfs() (string, error) {
+	dir, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		return "", err
+	}
+	if err := syscall.Mount("sysfs", dir, "sysfs", 0, ""); err != nil {
+		os.Remove(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// sendFrames sends n ethernet frames of given length from interface to dst
+func sendFrames(name string, dst net.HardwareAddr, n, length int) error {
+	i, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	frame := make([]byte, length)
+	copy(frame[0:6], dst)
+	copy(frame[6:12], i.HardwareAddr)
+	frame[12], frame[13] = ethPLocal>>8, ethPLocal&0xff
+	addr := &syscall.SockaddrLinklayer{Ifindex: i.Index, Halen: 6}
+	copy(addr.Addr[:], dst)
+	for k := 0; k < n; k++ {
+		if err := syscall.Sendto(fd, frame, 0, addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSysctl sets network sysctl of current network namespace
+func writeSysctl(name, value string) error {
+	path := filepath.Join("/proc/sys/net", strings.Replace(name, ".", "/", -1))
+	return ioutil.WriteFile(path, []byte(value), 0644)
+}
+
+func TestKernelCountersNetns(t *testing.T) {
+	if !runInNetns(t, "TestKernelCountersNetns") {
+		return
+	}
+
+	// router solicitations and MLD reports would disturb counted traffic
+	for _, conf := range []string{"all", "default"} {
+		if err := writeSysctl("ipv6.conf."+conf+".disable_ipv6", "1"); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+	}
+	peer := append(ifinfomsg(0), encodeAttr(syscall.IFLA_IFNAME, []byte("veth1\x00"))...)
+	if err := newLink("veth0", "veth", nil, encodeAttr(vethInfoPeer, peer)); err != nil {
+		t.Skip("Veth interfaces are not available, ", err)
+	}
+	// snooping bridge joins multicast router discovery group and reports it
+	if err := newLink("br0", "bridge", nil, encodeAttr(iflaBrMcastSnooping, []byte{0})); err != nil {
+		t.Skip("Bridge interfaces are not available, ", err)
+	}
+	if err := setLinkMaster("veth1", "br0"); err != nil {
+		t.Fatal(err)
+	}
+	veth0, err := net.InterfaceByName("veth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"veth0", "veth1", "br0"}
+	vlanID := []byte{0, 0}
+	nativeEndian.PutUint16(vlanID, 100)
+	vlan := newLink("veth0.100", "vlan", encodeAttr(syscall.IFLA_LINK, encodeU32(uint32(veth0.Index))), encodeAttr(iflaVlanID, vlanID)) == nil
+	if vlan {
+		names = append(names, "veth0.100")
+	} else {
+		t.Log("VLAN interfaces are not available, skipping VLAN traffic")
+	}
+	for _, name := range names {
+		if err := setLinkUp(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := waitOperUp(names, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	br0, err := net.InterfaceByName("br0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sysfs backend and sFlow counters need sysfs of the namespace
+	dir, err := mountSysfs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		syscall.Unmount(dir, 0)
+		os.Remove(dir)
+	}()
+	sysClassNet = filepath.Join(dir, "class", "net")
+	defer func() { sysClassNet = "/sys/class/net" }()
+
+	ifacePlg := &ifacePlugin{stats: map[string]interface{}{}}
+	backends := []string{statsBackendProcfs, statsBackendSysfs, statsBackendNetlink}
+	// collect returns counters of interfaces read by each backend
+	collect := func() map[string]map[string]int64 {
+		all := map[string]map[string]int64{}
+		for _, backend := range backends {
+			node := cdata.NewNode()
+			node.AddItem("stats_backend", ctypes.ConfigValueStr{Value: backend})
+			mTypes := []plugin.PluginMetricType{}
+			for _, name := range names {
+				for _, stat := range []string{"packets_recv", "packets_sent", "bytes_recv", "bytes_sent"} {
+					mTypes = append(mTypes, plugin.PluginMetricType{Namespace_: []string{VENDOR, FS, PLUGIN, name, stat}, Config_: node})
+				}
+			}
+			metrics, err := ifacePlg.CollectMetrics(mTypes)
+			So(err, ShouldBeNil)
+			values := map[string]int64{}
+			for _, m := range metrics {
+				values[m.Namespace()[3]+"/"+m.Namespace()[4]], _ = m.Data().(int64)
+			}
+			So(values, ShouldHaveLength, len(mTypes))
+			all[backend] = values
+		}
+		return all
+	}
+
+	Convey("Given veth pair, bridge and VLAN in network namespace", t, func() {
+		before := collect()
+
+		Convey("When known number of frames is sent", func() {
+			// frames to bridge address are delivered to the bridge itself,
+			// VLAN frames to unknown address are flooded to no other port
+			So(sendFrames("veth0", br0.HardwareAddr, 25, 100), ShouldBeNil)
+			packets, bytes := int64(25), int64(25*100)
+			if vlan {
+				So(sendFrames("veth0.100", net.HardwareAddr{2, 0, 0, 0, 0, 0x99}, 10, 60), ShouldBeNil)
+				// veth offloads VLAN tag, it is not counted in frame length
+				packets, bytes = packets+10, bytes+10*60
+			}
+
+			all := collect()
+			for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				done := true
+				for _, backend := range backends {
+					a, b := all[backend], before[backend]
+					done = done && a["veth1/packets_recv"]-b["veth1/packets_recv"] >= packets && a["br0/packets_recv"]-b["br0/packets_recv"] >= 25
+				}
+				if done {
+					break
+				}
+				all = collect()
+			}
+			after := all[statsBackendProcfs]
+
+			for _, backend := range backends {
+				backend := backend
+				delta := func(name string) int64 {
+					return all[backend][name] - before[backend][name]
+				}
+
+				Convey(fmt.Sprintf("Counters of %s backend change exactly by sent traffic", backend), func() {
+					So(delta("veth0/packets_sent"), ShouldEqual, packets)
+					So(delta("veth0/bytes_sent"), ShouldEqual, bytes)
+					So(delta("veth1/packets_recv"), ShouldEqual, packets)
+					So(delta("veth1/bytes_recv"), ShouldEqual, bytes)
+					So(delta("br0/packets_recv"), ShouldEqual, 25)
+					So(delta("br0/bytes_recv"), ShouldEqual, 25*(100-14))
+					So(delta("veth0/packets_recv"), ShouldEqual, 0)
+					So(delta("br0/packets_sent"), ShouldEqual, 0)
+					if vlan {
+						So(delta("veth0.100/packets_sent"), ShouldEqual, 10)
+						So(delta("veth0.100/bytes_sent"), ShouldEqual, 10*60)
+					}
+				})
+			}
+
+			Convey("Link details and sFlow counters agree with procfs counters", func() {
+				node := cdata.NewNode()
+				node.AddItem("link_details", ctypes.ConfigValueBool{Value: true})
+				mTypes := []plugin.PluginMetricType{}
+				for _, name := range names {
+					for _, ns := range []string{"bytes_recv", "packets_sent", "link/admin_up", "link/operstate"} {
+						mTypes = append(mTypes, plugin.PluginMetricType{Namespace_: strings.Split(VENDOR+"/"+FS+"/"+PLUGIN+"/"+name+"/"+ns, "/"), Config_: node})
+					}
+				}
+				metrics, err := ifacePlg.CollectMetrics(mTypes)
+				So(err, ShouldBeNil)
+				values := map[string]interface{}{}
+				for _, m := range metrics {
+					values[strings.Join(m.Namespace()[3:], "/")] = m.Data()
+				}
+
+				// sFlow counters take interface type and status from sysfs
+				counters, err := SFlowCounters()
+				So(err, ShouldBeNil)
+
+				for _, name := range names {
+					So(values[name+"/link/admin_up"], ShouldEqual, 1)
+					So(values[name+"/link/operstate"], ShouldEqual, ifOperUp)
+					So(values[name+"/bytes_recv"], ShouldEqual, after[name+"/bytes_recv"])
+
+					i, err := net.InterfaceByName(name)
+					So(err, ShouldBeNil)
+					found := false
+					for _, c := range counters {
+						if int(c.Index) != i.Index {
+							continue
+						}
+						found = true
+						So(c.Type, ShouldEqual, ifTypeEthernetCsmacd)
+						So(c.Status, ShouldEqual, 3)
+						So(int64(c.InOctets), ShouldEqual, after[name+"/bytes_recv"])
+						So(int64(c.OutUcastPkts), ShouldEqual, after[name+"/packets_sent"])
+					}
+					So(found, ShouldBeTrue)
+				}
+			})
+
+			Convey("Rtnetlink forwarding database learned the sender on bridge port", func() {
+				stats := map[string]interface{}{}
+				So(getFDBStats(stats, config{}, nil), ShouldBeNil)
+				port := stats[fdbKey].(map[string]interface{})["veth1"].(map[string]interface{})
+				So(port["dynamic"], ShouldResemble, taggedValue{value: int64(1), tags: map[string]string{"bridge": "br0"}})
+			})
+		})
+	})
+}
@@ -0,0 +1,229 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const (
+	// nested IFLA_LINKINFO attributes, see linux/if_link.h
+	iflaInfoKind      = 1
+	iflaInfoData      = 2
+	iflaInfoXstats    = 3
+	iflaInfoSlaveKind = 4
+
+	// link attributes missing in syscall package, see linux/if_link.h
+	iflaCarrier        = 33
+	iflaCarrierChanges = 35
+)
+
+// link is network interface as reported by rtnetlink
+type link struct {
+	index  int
+	name   string
+	master int
+	// kind is type of virtual interface, e.g. vxlan, empty for physical ones
+	kind      string
+	slaveKind string
+	// data holds kind specific IFLA_INFO_DATA attributes
+	data map[uint16][]byte
+	// xstats holds kind specific IFLA_INFO_XSTATS structure
+	xstats []byte
+	// attrs holds link attributes published as link details
+	attrs map[string]interface{}
+	// stats64 holds IFLA_STATS64 structure
+	stats64 []byte
+}
+
+// getLinks dumps interfaces of current network namespace
+func getLinks() ([]link, error) {
+	req := make([]byte, syscall.SizeofIfInfomsg)
+	msgs, err := nlRequest(syscall.NETLINK_ROUTE, syscall.RTM_GETLINK, syscall.NLM_F_DUMP, req)
+	if err != nil {
+		return nil, err
+	}
+	return parseLinks(msgs)
+}
+
+// parseLinks decodes RTM_NEWLINK messages
+func parseLinks(msgs []syscall.NetlinkMessage) ([]link, error) {
+	links := []link{}
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWLINK {
+			continue
+		}
+		if len(m.Data) < syscall.SizeofIfInfomsg {
+			return nil, fmt.Errorf("Truncated link message")
+		}
+		attrs, err := attrMap(m.Data[syscall.SizeofIfInfomsg:])
+		if err != nil {
+			return nil, err
+		}
+
+		l := link{
+			index: int(int32(nativeEndian.Uint32(m.Data[4:8]))),
+			name:  nlString(attrs[syscall.IFLA_IFNAME]),
+		}
+		l.stats64 = attrs[iflaStats64]
+		if b := attrs[syscall.IFLA_MASTER]; len(b) == 4 {
+			l.master = int(nativeEndian.Uint32(b))
+		}
+		l.attrs = map[string]interface{}{
+			"admin_up": int64(nativeEndian.Uint32(m.Data[8:12]) & syscall.IFF_UP),
+		}
+		for name, t := range map[string]uint16{"mtu": syscall.IFLA_MTU, "txqlen": syscall.IFLA_TXQLEN, "carrier_changes": iflaCarrierChanges} {
+			if b := attrs[t]; len(b) == 4 {
+				l.attrs[name] = int64(nativeEndian.Uint32(b))
+			}
+		}
+		for name, t := range map[string]uint16{"operstate": syscall.IFLA_OPERSTATE, "carrier": iflaCarrier} {
+			if b := attrs[t]; len(b) == 1 {
+				l.attrs[name] = int64(b[0])
+			}
+		}
+		if b, ok := attrs[syscall.IFLA_LINKINFO]; ok {
+			info, err := attrMap(b)
+			if err != nil {
+				return nil, err
+			}
+			l.kind = nlString(info[iflaInfoKind])
+			l.slaveKind = nlString(info[iflaInfoSlaveKind])
+			l.xstats = info[iflaInfoXstats]
+			if l.data, err = attrMap(info[iflaInfoData]); err != nil {
+				return nil, err
+			}
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
+
+// getIfaceTags returns tags of interfaces indexed by interface name,
+// links are dumped only when some of tag sources is enabled
+func getIfaceTags(configs map[string]config) (map[string]map[string]string, error) {
+	enabled := false
+	for _, cfg := range configs {
+		for _, s := range tagSources {
+			enabled = enabled || cfg.getBool(s.enable, false)
+		}
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	links, err := getLinks()
+	if err != nil {
+		return nil, err
+	}
+	return ifaceTags(links, configs), nil
+}
+
+// ifaceTags merges tags of tag sources enabled in config of each interface
+func ifaceTags(links []link, configs map[string]config) map[string]map[string]string {
+	tags := map[string]map[string]string{}
+	for _, s := range tagSources {
+		enabled := false
+		for _, cfg := range configs {
+			enabled = enabled || cfg.getBool(s.enable, false)
+		}
+		if !enabled {
+			continue
+		}
+		for name, t := range s.tags(links) {
+			if !configs[name].getBool(s.enable, false) {
+				continue
+			}
+			if tags[name] == nil {
+				tags[name] = map[string]string{}
+			}
+			for k, v := range t {
+				tags[name][k] = v
+			}
+		}
+	}
+	return tags
+}
+
+// eachLink adapts function which describes single link to tag source
+func eachLink(fn func(l link) map[string]string) func(links []link) map[string]map[string]string {
+	return func(links []link) map[string]map[string]string {
+		tags := map[string]map[string]string{}
+		for _, l := range links {
+			if t := fn(l); t != nil {
+				tags[l.name] = t
+			}
+		}
+		return tags
+	}
+}
+
+// getLinkDetails adds link attributes and driver statistics of interfaces of
+// current network namespace to their stats under link and driver elements
+func getLinkDetails(stats map[string]interface{}) error {
+	links, err := getLinks()
+	if err != nil {
+		return err
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(fd)
+
+	for _, l := range links {
+		istats, ok := stats[l.name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		attrs := map[string]interface{}{}
+		for k, v := range l.attrs {
+			attrs[k] = v
+		}
+		if speed, ok := ethtoolSpeed(fd, l.name); ok {
+			attrs["speed"] = speed
+		}
+		istats["link"] = attrs
+
+		// most virtual devices have no driver statistics
+		if dstats, err := ethtoolStats(fd, l.name); err == nil && len(dstats) > 0 {
+			istats["driver"] = dstats
+		}
+	}
+	return nil
+}
+
+// dropLinkDetails removes link details of interfaces which config does not
+// enable them, they are read for all interfaces when any of them needs them
+func dropLinkDetails(stats map[string]interface{}, configOf func(name string) config) {
+	for name, istats := range stats {
+		m, ok := istats.(map[string]interface{})
+		if !ok || strings.HasPrefix(name, "_") || configOf(name).getBool("link_details", false) {
+			continue
+		}
+		delete(m, "link")
+		delete(m, "driver")
+	}
+}
@@ -0,0 +1,149 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"io/ioutil"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// readLinks returns links of recorded rtnetlink dump
+func readLinks(path string) ([]link, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := parseNetlink(b)
+	if err != nil {
+		return nil, err
+	}
+	return parseLinks(msgs)
+}
+
+func TestParseLinks(t *testing.T) {
+	Convey("Given recorded link dump", t, func() {
+		Convey("When links are parsed", func() {
+			links, err := readLinks("../examples/test/rtnl.links")
+			So(err, ShouldBeNil)
+
+			Convey("All interfaces are returned", func() {
+				So(len(links), ShouldEqual, 7)
+				So(links[0].name, ShouldEqual, "lo")
+				So(links[0].index, ShouldEqual, 1)
+				So(links[0].kind, ShouldBeEmpty)
+				So(links[1].name, ShouldEqual, "vxlan100")
+				So(links[1].index, ShouldEqual, 2)
+				So(links[1].kind, ShouldEqual, "vxlan")
+				So(links[1].data, ShouldNotBeEmpty)
+			})
+
+			Convey("Link attributes are decoded", func() {
+				So(links[0].attrs["mtu"], ShouldEqual, 65536)
+				So(links[0].attrs["operstate"], ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestIfaceTags(t *testing.T) {
+	Convey("Given recorded link dump", t, func() {
+		links, err := readLinks("../examples/test/rtnl.links")
+		So(err, ShouldBeNil)
+
+		Convey("When tunnel tags are enabled", func() {
+			on := config{"tunnel_tags": ctypes.ConfigValueBool{Value: true}}
+			tags := ifaceTags(links, map[string]config{"vxlan100": on, "gre1": on, "lo": on})
+
+			Convey("Tunnel interfaces are tagged", func() {
+				So(tags["vxlan100"]["vni"], ShouldEqual, "100")
+				So(tags["gre1"]["kind"], ShouldEqual, "gre")
+				So(tags, ShouldNotContainKey, "lo")
+			})
+		})
+
+		Convey("When tunnel tags are enabled for some interfaces", func() {
+			on := config{"tunnel_tags": ctypes.ConfigValueBool{Value: true}}
+			tags := ifaceTags(links, map[string]config{"vxlan100": on, "gre1": config{}})
+
+			Convey("Only those interfaces are tagged", func() {
+				So(tags["vxlan100"]["vni"], ShouldEqual, "100")
+				So(tags, ShouldNotContainKey, "gre1")
+			})
+		})
+
+		Convey("When no tags are enabled", func() {
+			tags := ifaceTags(links, map[string]config{"vxlan100": config{}, "gre1": config{}})
+
+			Convey("No interface is tagged", func() {
+				So(tags, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestDropLinkDetails(t *testing.T) {
+	Convey("Given stats with link details of all interfaces", t, func() {
+		stats := map[string]interface{}{
+			"eth0":    map[string]interface{}{"bytes_recv": int64(1), "link": map[string]interface{}{"mtu": int64(1500)}},
+			"tap0":    map[string]interface{}{"bytes_recv": int64(2), "link": map[string]interface{}{"mtu": int64(1500)}, "driver": map[string]interface{}{"peer_ifindex": int64(3)}},
+			"_tcp":    map[string]interface{}{"link": map[string]interface{}{}},
+			"_sample": int64(0),
+		}
+		cfg := config{"link_details@^eth": ctypes.ConfigValueBool{Value: true}}
+		resolve, err := cfg.perInterface()
+		So(err, ShouldBeNil)
+
+		Convey("When details of interfaces which config does not enable are dropped", func() {
+			dropLinkDetails(stats, resolve)
+
+			Convey("Only enabled interfaces keep them", func() {
+				So(stats["eth0"], ShouldContainKey, "link")
+				So(stats["tap0"], ShouldNotContainKey, "link")
+				So(stats["tap0"], ShouldNotContainKey, "driver")
+				So(stats["tap0"], ShouldContainKey, "bytes_recv")
+				So(stats["_tcp"], ShouldContainKey, "link")
+			})
+		})
+	})
+}
+
+func TestGetLinksNetns(t *testing.T) {
+	if !runInNetns(t, "TestGetLinksNetns") {
+		return
+	}
+
+	Convey("Given network namespace", t, func() {
+		Convey("When links are dumped", func() {
+			links, err := getLinks()
+
+			Convey("Loopback interface is returned", func() {
+				So(err, ShouldBeNil)
+				So(len(links), ShouldEqual, 1)
+				So(links[0].name, ShouldEqual, "lo")
+			})
+		})
+	})
+}
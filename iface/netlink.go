@@ -0,0 +1,164 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// nlaTypeMask strips nested and byte order flags from attribute type
+	nlaTypeMask = 0x3fff
+	nlaHdrLen   = 4
+)
+
+// nativeEndian is byte order of netlink headers and host order attributes
+var nativeEndian binary.ByteOrder
+
+var nlSeq uint32
+
+func init() {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// nlAttr is single netlink attribute with type flags stripped
+type nlAttr struct {
+	Type uint16
+	Data []byte
+}
+
+// parseAttrs splits buffer into list of netlink attributes
+func parseAttrs(b []byte) ([]nlAttr, error) {
+	attrs := []nlAttr{}
+	for len(b) >= nlaHdrLen {
+		l := int(nativeEndian.Uint16(b[0:2]))
+		if l < nlaHdrLen || l > len(b) {
+			return nil, fmt.Errorf("Wrong netlink attribute length {%d}", l)
+		}
+		attrs = append(attrs, nlAttr{
+			Type: nativeEndian.Uint16(b[2:4]) & nlaTypeMask,
+			Data: b[nlaHdrLen:l],
+		})
+		if l = nlaAlign(l); l > len(b) {
+			l = len(b)
+		}
+		b = b[l:]
+	}
+	return attrs, nil
+}
+
+// attrMap returns attributes indexed by type, for repeated types last one wins
+func attrMap(b []byte) (map[uint16][]byte, error) {
+	attrs, err := parseAttrs(b)
+	if err != nil {
+		return nil, err
+	}
+	m := map[uint16][]byte{}
+	for _, a := range attrs {
+		m[a.Type] = a.Data
+	}
+	return m, nil
+}
+
+func nlaAlign(l int) int {
+	return (l + syscall.NLA_ALIGNTO - 1) &^ (syscall.NLA_ALIGNTO - 1)
+}
+
+// parseNetlink splits raw netlink buffer into messages, it is used both
+// for kernel responses and for recorded dumps
+func parseNetlink(b []byte) ([]syscall.NetlinkMessage, error) {
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse netlink message: %v", err)
+	}
+	return msgs, nil
+}
+
+// nlRequest sends request to the kernel over netlink socket of given protocol
+// and gathers all response messages, for dump requests until end of dump
+func nlRequest(proto int, msgType uint16, flags uint16, payload []byte) ([]syscall.NetlinkMessage, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	seq := atomic.AddUint32(&nlSeq, 1)
+	req := make([]byte, syscall.NLMSG_HDRLEN+len(payload))
+	nativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	nativeEndian.PutUint16(req[4:6], msgType)
+	nativeEndian.PutUint16(req[6:8], flags|syscall.NLM_F_REQUEST)
+	nativeEndian.PutUint32(req[8:12], seq)
+	copy(req[syscall.NLMSG_HDRLEN:], payload)
+
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, os.NewSyscallError("sendto", err)
+	}
+
+	msgs := []syscall.NetlinkMessage{}
+	buf := make([]byte, 32*1024)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, os.NewSyscallError("recvfrom", err)
+		}
+		resp, err := parseNetlink(append([]byte(nil), buf[:n]...))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range resp {
+			if m.Header.Seq != seq {
+				continue
+			}
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return msgs, nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return nil, fmt.Errorf("Truncated netlink error message")
+				}
+				if errno := int32(nativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				return msgs, nil
+			}
+			msgs = append(msgs, m)
+			if m.Header.Flags&syscall.NLM_F_MULTI == 0 {
+				return msgs, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// netnsKey names network namespaces in list of sources disabled for missing capabilities
+const netnsKey = "netns"
+
+// netnsTarget is network namespace entered to read its interfaces,
+// its metrics are published with name as source
+type netnsTarget struct {
+	name string
+	path string
+}
+
+// parseNetns parses comma separated list of namespaces in format name=path,
+// e.g. web=/var/run/netns/web,pod=/proc/1234/ns/net
+func parseNetns(s string) ([]netnsTarget, error) {
+	namespaces := []netnsTarget{}
+	err := parseNamed(s, "network namespace", func(name, path string) {
+		namespaces = append(namespaces, netnsTarget{name: name, path: path})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// stats reads interface statistics of the namespace, link details are added when enabled
+func (n netnsTarget) stats(details bool) (map[string]interface{}, error) {
+	stats := map[string]interface{}{}
+	err := inNetns(n.path, func() error {
+		// unlike /proc/net, /proc/self/task/<tid>/net follows namespace of the thread
+		if err := readNetDev(fmt.Sprintf("/proc/self/task/%d/net/dev", syscall.Gettid()), stats); err != nil {
+			return err
+		}
+		if details {
+			return getLinkDetails(stats)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// inNetns runs fn on OS thread switched to network namespace at path, sockets
+// opened by fn belong to the namespace; fn runs in its own goroutine which
+// locks the thread, so the caller never runs in the namespace
+func inNetns(path string, fn func() error) error {
+	result := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		self, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			result <- err
+			return
+		}
+		defer self.Close()
+		target, err := os.Open(path)
+		if err != nil {
+			runtime.UnlockOSThread()
+			result <- err
+			return
+		}
+		defer target.Close()
+
+		if err := setns(int(target.Fd())); err != nil {
+			runtime.UnlockOSThread()
+			result <- os.NewSyscallError("setns", err)
+			return
+		}
+		fnErr := fn()
+		if err := setns(int(self.Fd())); err != nil {
+			// thread stays in the namespace, so the goroutine never returns and
+			// keeps it locked; go before 1.10 would give the thread to other
+			// goroutines even if the goroutine exited with it locked
+			self.Close()
+			target.Close()
+			result <- fmt.Errorf("Cannot return from network namespace {%s}, %v", path, err)
+			select {}
+		}
+		runtime.UnlockOSThread()
+		result <- fnErr
+	}()
+	return <-result
+}
+
+func setns(fd int) error {
+	_, _, errno := syscall.Syscall(sysSetns, uintptr(fd), syscall.CLONE_NEWNET, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
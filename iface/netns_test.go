@@ -0,0 +1,253 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// iflaNetNsFd moves created link into network namespace of given file
+const iflaNetNsFd = 28
+
+func TestParseNetns(t *testing.T) {
+	Convey("Given list of network namespaces", t, func() {
+		Convey("When it is parsed", func() {
+			namespaces, err := parseNetns("web=/var/run/netns/web, pod=/proc/1234/ns/net")
+
+			Convey("Named namespaces are returned", func() {
+				So(err, ShouldBeNil)
+				So(namespaces, ShouldResemble, []netnsTarget{
+					{name: "web", path: "/var/run/netns/web"},
+					{name: "pod", path: "/proc/1234/ns/net"},
+				})
+			})
+		})
+
+		Convey("When namespace has the name of procfs root", func() {
+			cfg := config{
+				"proc_roots": ctypes.ConfigValueStr{Value: "web=/mnt/web/proc"},
+				"netns":      ctypes.ConfigValueStr{Value: "web=/var/run/netns/web"},
+			}
+			_, _, err := parseNamedConfig(cfg)
+
+			Convey("Error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+// newNetns creates network namespace and returns its file, the calling
+// thread stays in its namespace
+func newNetns() (*os.File, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	self, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	if err != nil {
+		return nil, err
+	}
+	defer self.Close()
+	if err := syscall.Unshare(syscall.CLONE_NEWNET); err != nil {
+		return nil, err
+	}
+	ns, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	if serr := setns(int(self.Fd())); serr != nil {
+		panic(serr)
+	}
+	return ns, err
+}
+
+func TestNetnsStatsNetns(t *testing.T) {
+	if !runInNetns(t, "TestNetnsStatsNetns") {
+		return
+	}
+
+	ns, err := newNetns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ns.Close()
+	nsPath := fmt.Sprintf("/proc/self/fd/%d", ns.Fd())
+
+	// router solicitations and MLD reports would disturb counted traffic
+	disableIPv6 := func() error {
+		for _, conf := range []string{"all", "default"} {
+			if err := writeSysctl("ipv6.conf."+conf+".disable_ipv6", "1"); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := disableIPv6(); err != nil {
+		t.Fatal(err)
+	}
+	peer := append(ifinfomsg(0), encodeAttr(syscall.IFLA_IFNAME, []byte("veth1\x00"))...)
+	peer = append(peer, encodeAttr(iflaNetNsFd, encodeU32(uint32(ns.Fd())))...)
+	if err := newLink("veth0", "veth", nil, encodeAttr(vethInfoPeer, peer)); err != nil {
+		t.Skip("Veth interfaces are not available, ", err)
+	}
+	if err := setLinkUp("veth0"); err != nil {
+		t.Fatal(err)
+	}
+	var veth1 *net.Interface
+	err = inNetns(nsPath, func() error {
+		if err := disableIPv6(); err != nil {
+			return err
+		}
+		if err := setLinkUp("veth1"); err != nil {
+			return err
+		}
+		if err := waitOperUp([]string{"veth1"}, 5*time.Second); err != nil {
+			return err
+		}
+		veth1, err = net.InterfaceByName("veth1")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := waitOperUp([]string{"veth0"}, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	veth0, err := net.InterfaceByName("veth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := cdata.NewNode()
+	node.AddItem("netns", ctypes.ConfigValueStr{Value: "peer=" + nsPath})
+	node.AddItem("link_details", ctypes.ConfigValueBool{Value: true})
+	ifacePlg := &ifacePlugin{stats: map[string]interface{}{}, host: "host"}
+
+	Convey("Given veth pair with peer in other network namespace", t, func() {
+		Convey("When catalog is requested", func() {
+			mts, err := ifacePlg.GetMetricTypes(plugin.PluginConfigType{ConfigDataNode: node})
+			So(err, ShouldBeNil)
+			namespaces := []string{}
+			for _, m := range mts {
+				namespaces = append(namespaces, strings.Join(m.Namespace()[3:], "/"))
+			}
+
+			Convey("Interfaces of both namespaces have link details", func() {
+				So(namespaces, ShouldContain, "veth0/link/mtu")
+				So(namespaces, ShouldContain, "veth1/bytes_recv")
+				So(namespaces, ShouldContain, "veth1/link/operstate")
+				So(namespaces, ShouldContain, "veth1/driver/peer_ifindex")
+			})
+		})
+
+		Convey("When frames are sent to the peer", func() {
+			So(sendFrames("veth0", veth1.HardwareAddr, 25, 100), ShouldBeNil)
+			mTypes := []plugin.PluginMetricType{}
+			for _, ns := range []string{"veth0/bytes_sent", "veth0/link/mtu", "veth1/bytes_recv", "veth1/link/operstate", "veth1/link/speed", "veth1/driver/peer_ifindex"} {
+				mTypes = append(mTypes, plugin.PluginMetricType{Namespace_: strings.Split("intel/procfs/iface/"+ns, "/"), Config_: node})
+			}
+			metrics, err := ifacePlg.CollectMetrics(mTypes)
+			So(err, ShouldBeNil)
+			values := map[string]interface{}{}
+			for _, m := range metrics {
+				values[m.Source_+":"+strings.Join(m.Namespace()[3:], "/")] = m.Data_
+			}
+
+			Convey("Peer statistics are published with namespace name as source", func() {
+				So(values["host:veth0/bytes_sent"], ShouldEqual, 25*100)
+				So(values["peer:veth1/bytes_recv"], ShouldEqual, 25*100)
+				So(values, ShouldNotContainKey, "peer:veth0/bytes_sent")
+			})
+
+			Convey("Link attributes and driver statistics are read within namespace", func() {
+				So(values["host:veth0/link/mtu"], ShouldEqual, 1500)
+				So(values["peer:veth1/link/operstate"], ShouldEqual, ifOperUp)
+				So(values["peer:veth1/link/speed"], ShouldEqual, 10000)
+				So(values["peer:veth1/driver/peer_ifindex"], ShouldEqual, veth0.Index)
+			})
+		})
+	})
+}
+
+func TestInNetnsNetns(t *testing.T) {
+	if !runInNetns(t, "TestInNetnsNetns") {
+		return
+	}
+
+	ns, err := newNetns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ns.Close()
+	nsPath := fmt.Sprintf("/proc/self/fd/%d", ns.Fd())
+	netnsOf := func(tid int) (string, error) {
+		return os.Readlink(fmt.Sprintf("/proc/self/task/%d/ns/net", tid))
+	}
+
+	Convey("Given caller locked to its OS thread", t, func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		caller := syscall.Gettid()
+		before, err := netnsOf(caller)
+		So(err, ShouldBeNil)
+		target, err := os.Readlink(nsPath)
+		So(err, ShouldBeNil)
+
+		Convey("When function runs in other network namespace", func() {
+			var tid int
+			var inside string
+			err := inNetns(nsPath, func() error {
+				tid = syscall.Gettid()
+				var err error
+				inside, err = netnsOf(tid)
+				return err
+			})
+
+			Convey("It runs on other thread switched to the namespace", func() {
+				So(err, ShouldBeNil)
+				So(tid, ShouldNotEqual, caller)
+				So(inside, ShouldEqual, target)
+				after, err := netnsOf(caller)
+				So(err, ShouldBeNil)
+				So(after, ShouldEqual, before)
+			})
+		})
+
+		Convey("When function fails", func() {
+			err := inNetns(nsPath, func() error { return fmt.Errorf("failed") })
+
+			Convey("Its error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "failed")
+			})
+		})
+	})
+}
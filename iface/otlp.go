@@ -0,0 +1,231 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/intelsdi-x/snap/core/ctypes"
+
+	"github.com/intelsdi-x/snap-plugin-collector-interface/otlp"
+)
+
+// OpenTelemetry attributes of interface data points
+const (
+	otlpIfaceAttr     = "network.interface.name"
+	otlpDirectionAttr = "network.io.direction"
+)
+
+// otlpCounters maps statistics of /proc/net/dev to OpenTelemetry metrics and
+// units, statistics which are not listed are exported as system.network.<stat>
+var otlpCounters = map[string][2]string{
+	"bytes":   {"system.network.io", "By"},
+	"packets": {"system.network.packets", "{packet}"},
+	"errs":    {"system.network.errors", "{error}"},
+	"drop":    {"system.network.dropped", "{packet}"},
+}
+
+// otlpRates lists counters which are exported also as rates
+var otlpRates = map[string]string{
+	"bytes":   "By/s",
+	"packets": "{packet}/s",
+}
+
+// otlpSample is statistics of interface read at given time
+type otlpSample struct {
+	stats map[string]interface{}
+	time  time.Time
+}
+
+// OTLPReader reads interface statistics as OpenTelemetry metrics, counters
+// are cumulative sums and rates are gauges computed between reads
+type OTLPReader struct {
+	tags bool
+	boot time.Time
+	// last is time of previous read, zero before the first one
+	last time.Time
+	prev map[string]otlpSample
+	// starts holds start time of each counter of interfaces, indexed by
+	// interface name and statistic
+	starts map[string]map[string]time.Time
+}
+
+// NewOTLPReader creates reader, tags adds tunnel, VM and VRF tags of
+// interfaces to attributes of their data points
+func NewOTLPReader(tags bool) *OTLPReader {
+	boot, err := bootTime()
+	if err != nil {
+		log.Warn("Cannot read boot time, counters start at first read, ", err)
+		boot = now()
+	}
+	return &OTLPReader{tags: tags, boot: boot, prev: map[string]otlpSample{}, starts: map[string]map[string]time.Time{}}
+}
+
+// bootTime reads time of boot from /proc/stat
+func bootTime() (time.Time, error) {
+	content, err := ioutil.ReadFile(filepath.Join(procPath, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "btime" {
+			sec, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("No btime in %s", filepath.Join(procPath, "stat"))
+}
+
+// Metrics reads statistics of all interfaces
+func (r *OTLPReader) Metrics() ([]otlp.Metric, error) {
+	stats := map[string]interface{}{}
+	if err := getStats(stats, statsBackendProcfs); err != nil {
+		return nil, err
+	}
+	t := now()
+
+	var tags map[string]map[string]string
+	if r.tags {
+		configs := map[string]config{}
+		for name := range stats {
+			configs[name] = config{}
+			for _, s := range tagSources {
+				configs[name][s.enable] = ctypes.ConfigValueBool{Value: true}
+			}
+		}
+		var err error
+		if tags, err = getIfaceTags(configs); err != nil {
+			log.Warn("Cannot read interface tags, ", err)
+		}
+	}
+
+	metrics := map[string]*otlp.Metric{}
+	add := func(name, unit string, kind otlp.Kind, p otlp.Point) {
+		m, ok := metrics[name]
+		if !ok {
+			m = &otlp.Metric{Name: name, Unit: unit, Kind: kind}
+			metrics[name] = m
+		}
+		m.Points = append(m.Points, p)
+	}
+
+	for _, name := range sortedKeys(stats) {
+		istats := stats[name].(map[string]interface{})
+		prev, hasPrev := r.prev[name]
+		r.prev[name] = otlpSample{stats: istats, time: t}
+		starts, ok := r.starts[name]
+		if !ok {
+			starts = map[string]time.Time{}
+			r.starts[name] = starts
+		}
+
+		for _, stat := range sortedKeys(istats) {
+			v, ok := istats[stat].(int64)
+			if !ok || v < 0 {
+				continue
+			}
+			counter, direction := otlpDirection(stat)
+			attrs := map[string]string{otlpIfaceAttr: name, otlpDirectionAttr: direction}
+			for k, v := range tags[name] {
+				attrs[k] = v
+			}
+
+			metric, ok := otlpCounters[counter]
+			if !ok {
+				metric = [2]string{"system.network." + counter, ""}
+			}
+			pv, hasPv := prev.stats[stat].(int64)
+			hasPv = hasPrev && hasPv && pv >= 0
+			start, ok := starts[stat]
+			switch {
+			case !ok && r.last.IsZero():
+				// counters of interfaces present at first read are taken
+				// as counted since boot
+				start = r.boot
+			case !ok:
+				// interface appeared since previous read
+				start = r.last
+			case hasPv && pv > v:
+				// counters are reset e.g. when driver is reloaded
+				start = prev.time
+			}
+			starts[stat] = start
+			add(metric[0], metric[1], otlp.Sum, otlp.Point{Attributes: attrs, Start: start, Time: t, Value: v})
+
+			unit, ok := otlpRates[counter]
+			if !ok || !hasPv {
+				continue
+			}
+			elapsed := t.Sub(prev.time).Seconds()
+			if pv > v || elapsed <= 0 {
+				continue
+			}
+			add(metric[0]+".rate", unit, otlp.Gauge, otlp.Point{Attributes: attrs, Time: t, Value: float64(v-pv) / elapsed})
+		}
+	}
+	for name := range r.prev {
+		if _, ok := stats[name]; !ok {
+			delete(r.prev, name)
+			delete(r.starts, name)
+		}
+	}
+	r.last = t
+
+	names := []string{}
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := []otlp.Metric{}
+	for _, name := range names {
+		result = append(result, *metrics[name])
+	}
+	return result, nil
+}
+
+// otlpDirection splits statistic of /proc/net/dev into counter and direction
+func otlpDirection(stat string) (string, string) {
+	if strings.HasSuffix(stat, "_sent") {
+		return strings.TrimSuffix(stat, "_sent"), "transmit"
+	}
+	return strings.TrimSuffix(stat, "_recv"), "receive"
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-collector-interface/otlp"
+)
+
+func TestOTLPReader(t *testing.T) {
+	Convey("Given OTLP reader of interface statistics", t, func() {
+		content, err := ioutil.ReadFile("../examples/test/proc.net.dev")
+		So(err, ShouldBeNil)
+		f, err := ioutil.TempFile("", "net.dev")
+		So(err, ShouldBeNil)
+		defer os.Remove(f.Name())
+		So(ioutil.WriteFile(f.Name(), content, 0644), ShouldBeNil)
+
+		clock := time.Unix(1475000600, 0)
+		procPath, ifaceInfo, now = "../examples/test/proc", f.Name(), func() time.Time { return clock }
+		defer func() { procPath, ifaceInfo, now = "/proc", "/proc/net/dev", time.Now }()
+
+		r := NewOTLPReader(false)
+		find := func(metrics []otlp.Metric, name string) *otlp.Metric {
+			for i := range metrics {
+				if metrics[i].Name == name {
+					return &metrics[i]
+				}
+			}
+			return nil
+		}
+		point := func(m *otlp.Metric, iface, direction string) *otlp.Point {
+			for i, p := range m.Points {
+				if p.Attributes["network.interface.name"] == iface && p.Attributes["network.io.direction"] == direction {
+					return &m.Points[i]
+				}
+			}
+			return nil
+		}
+
+		Convey("When metrics are read first time", func() {
+			metrics, err := r.Metrics()
+			So(err, ShouldBeNil)
+
+			Convey("Counters are cumulative sums since boot", func() {
+				io := find(metrics, "system.network.io")
+				So(io, ShouldNotBeNil)
+				So(io.Kind, ShouldEqual, otlp.Sum)
+				So(io.Unit, ShouldEqual, "By")
+				So(io.Points, ShouldHaveLength, 4)
+				p := point(io, "p3p1", "transmit")
+				So(p, ShouldNotBeNil)
+				So(p.Value, ShouldEqual, int64(6434234393))
+				So(p.Start, ShouldResemble, time.Unix(1475000000, 0))
+				So(p.Time, ShouldResemble, clock)
+
+				So(find(metrics, "system.network.packets"), ShouldNotBeNil)
+				So(find(metrics, "system.network.errors").Unit, ShouldEqual, "{error}")
+				So(point(find(metrics, "system.network.multicast"), "lo", "receive"), ShouldNotBeNil)
+			})
+
+			Convey("No rates are known yet", func() {
+				So(find(metrics, "system.network.io.rate"), ShouldBeNil)
+			})
+
+			Convey("When counters grow", func() {
+				grown := strings.Replace(string(content), "1412848320", "1412858320", 1)
+				So(ioutil.WriteFile(f.Name(), []byte(grown), 0644), ShouldBeNil)
+				clock = clock.Add(10 * time.Second)
+				metrics, err := r.Metrics()
+				So(err, ShouldBeNil)
+
+				Convey("Sums keep their start", func() {
+					So(point(find(metrics, "system.network.io"), "p3p1", "receive").Start, ShouldResemble, time.Unix(1475000000, 0))
+				})
+
+				Convey("Rates since previous read are gauges", func() {
+					rate := find(metrics, "system.network.io.rate")
+					So(rate, ShouldNotBeNil)
+					So(rate.Kind, ShouldEqual, otlp.Gauge)
+					So(rate.Unit, ShouldEqual, "By/s")
+					So(point(rate, "p3p1", "receive").Value, ShouldEqual, 1000.0)
+					So(point(rate, "lo", "transmit").Value, ShouldEqual, 0.0)
+					So(find(metrics, "system.network.packets.rate"), ShouldNotBeNil)
+				})
+			})
+
+			Convey("When counters are reset", func() {
+				reset := strings.Replace(string(content), "1412848320", "1000", 1)
+				So(ioutil.WriteFile(f.Name(), []byte(reset), 0644), ShouldBeNil)
+				clock = clock.Add(10 * time.Second)
+				metrics, err := r.Metrics()
+				So(err, ShouldBeNil)
+
+				Convey("No rate is computed across reset", func() {
+					rate := find(metrics, "system.network.io.rate")
+					So(point(rate, "p3p1", "receive"), ShouldBeNil)
+					So(point(rate, "p3p1", "transmit"), ShouldNotBeNil)
+				})
+
+				Convey("Reset sum starts at previous read", func() {
+					io := find(metrics, "system.network.io")
+					So(point(io, "p3p1", "receive").Start, ShouldResemble, time.Unix(1475000600, 0))
+					So(point(io, "p3p1", "transmit").Start, ShouldResemble, time.Unix(1475000000, 0))
+				})
+			})
+
+			Convey("When interface appears", func() {
+				added := string(content) + "  veth9:    1000      10    0    0    0     0          0         0     2000       20    0    0    0     0       0          0\n"
+				So(ioutil.WriteFile(f.Name(), []byte(added), 0644), ShouldBeNil)
+				clock = clock.Add(10 * time.Second)
+				metrics, err := r.Metrics()
+				So(err, ShouldBeNil)
+
+				Convey("Its sums start at previous read", func() {
+					p := point(find(metrics, "system.network.io"), "veth9", "transmit")
+					So(p, ShouldNotBeNil)
+					So(p.Value, ShouldEqual, int64(2000))
+					So(p.Start, ShouldResemble, time.Unix(1475000600, 0))
+				})
+			})
+		})
+	})
+}
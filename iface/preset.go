@@ -0,0 +1,113 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// presetKey is config item selecting preset
+const presetKey = "preset"
+
+var (
+	itemOn  = ctypes.ConfigValueBool{Value: true}
+	itemOff = ctypes.ConfigValueBool{Value: false}
+)
+
+func itemInt(n int) ctypes.ConfigValueInt { return ctypes.ConfigValueInt{Value: n} }
+
+// presets are configs of common host roles, items set explicitly win over
+// items of preset
+var presets = map[string]config{
+	// minimal publishes interface statistics only
+	"minimal": {
+		"tcp_info": itemOff, "packet_sample": itemOff, "conntrack_top": itemOff, "conntrack_entries": itemOff,
+		"firewall": itemOff, "fdb": itemOff, "can": itemOff, "vrf": itemOff,
+		"tunnel_tags": itemOff, "vm_tags": itemOff, "link_details": itemOff, "netns_totals": itemOff,
+		"max_interfaces": itemInt(32), "max_elements": itemInt(10),
+	},
+	// container-host watches traffic of pods behind veth pairs and bridges
+	"container-host": {
+		"netns_totals": itemOn, "conntrack_entries": itemOn, "fdb": itemOn, "tcp_info": itemOn,
+		"tunnel_tags@^(vxlan|flannel|cilium|geneve)": itemOn,
+		"max_interfaces": itemInt(64), "max_elements": itemInt(50),
+	},
+	// router watches forwarding between VRFs, tunnels and firewall rules
+	"router": {
+		"vrf": itemOn, "tunnel_tags": itemOn, "link_details": itemOn, "firewall": itemOn,
+		"conntrack_top": itemOn, "conntrack_top_n": itemInt(20), "conntrack_entries": itemOn,
+		"tcp_info": itemOn, "tcp_aggregate": ctypes.ConfigValueStr{Value: aggregateBySubnet},
+		"max_interfaces": itemInt(256), "max_elements": itemInt(100),
+	},
+	// hypervisor attributes tap interfaces to virtual machines
+	"hypervisor": {
+		"vm_tags@^(tap|vnet|macvtap)[0-9]+$": itemOn, "tunnel_tags": itemOn, "fdb": itemOn, "link_details": itemOn,
+		"max_interfaces": itemInt(512), "max_elements": itemInt(100),
+	},
+	// storage watches few fast links and connection quality of storage protocols
+	"storage": {
+		"link_details": itemOn, "tcp_info": itemOn, "tcp_aggregate": ctypes.ConfigValueStr{Value: aggregateByPort},
+		"max_interfaces": itemInt(16), "max_elements": itemInt(20),
+	},
+}
+
+// presetNames returns sorted names of presets
+func presetNames() []string {
+	names := []string{}
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withPreset returns config with items of selected preset added, item of
+// preset is skipped when config sets the item or any override of it
+func (c config) withPreset() (config, error) {
+	name := c.getString(presetKey, "")
+	if name == "" {
+		return c, nil
+	}
+	preset, ok := presets[name]
+	if !ok {
+		return c, fmt.Errorf("Unknown preset {%s}, expected one of %v", name, presetNames())
+	}
+
+	explicit := map[string]bool{}
+	for k := range c {
+		explicit[strings.SplitN(k, overrideSep, 2)[0]] = true
+	}
+	res := config{}
+	for k, v := range preset {
+		if !explicit[strings.SplitN(k, overrideSep, 2)[0]] {
+			res[k] = v
+		}
+	}
+	for k, v := range c {
+		res[k] = v
+	}
+	return res, nil
+}
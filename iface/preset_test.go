@@ -0,0 +1,120 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestPresets(t *testing.T) {
+	Convey("Given config policy of the plugin", t, func() {
+		policy, err := (&ifacePlugin{}).GetConfigPolicy()
+		So(err, ShouldBeNil)
+		rules := policy.Get([]string{VENDOR, FS, PLUGIN})
+
+		Convey("Items of presets are valid config items", func() {
+			for _, preset := range presets {
+				items := map[string]ctypes.ConfigValue{}
+				for k, v := range preset {
+					items[strings.SplitN(k, overrideSep, 2)[0]] = v
+				}
+				_, errs := rules.Process(items)
+				So(errs.Errors(), ShouldBeEmpty)
+				for k := range preset {
+					if strings.Contains(k, overrideSep) {
+						_, _, err := parseOverride(k)
+						So(err, ShouldBeNil)
+					}
+				}
+			}
+		})
+
+		Convey("Items of presets have no default which snap would fill in", func() {
+			items := map[string]ctypes.ConfigValue{}
+			rules.Process(items)
+			for _, preset := range presets {
+				for k := range preset {
+					So(items, ShouldNotContainKey, strings.SplitN(k, overrideSep, 2)[0])
+				}
+			}
+		})
+	})
+
+	Convey("Given config with preset", t, func() {
+		node := cdata.NewNode()
+		node.AddItem("preset", ctypes.ConfigValueStr{Value: "hypervisor"})
+		node.AddItem("max_interfaces", ctypes.ConfigValueInt{Value: 8})
+
+		Convey("When config is created", func() {
+			cfg := newConfig(node)
+
+			Convey("Items of preset are added", func() {
+				So(cfg.getBool("fdb", false), ShouldBeTrue)
+				So(cfg.getInt("max_elements", 0), ShouldEqual, 100)
+			})
+
+			Convey("Explicit items win", func() {
+				So(cfg.getInt("max_interfaces", 0), ShouldEqual, 8)
+			})
+
+			Convey("Overrides of preset apply to matching interfaces", func() {
+				vnet, err := cfg.forInterface("vnet3")
+				So(err, ShouldBeNil)
+				So(vnet.getBool("vm_tags", false), ShouldBeTrue)
+				eth, err := cfg.forInterface("eth0")
+				So(err, ShouldBeNil)
+				So(eth.getBool("vm_tags", false), ShouldBeFalse)
+			})
+		})
+
+		Convey("When item which preset overrides per interface is set", func() {
+			node.AddItem("vm_tags", ctypes.ConfigValueBool{Value: false})
+			vnet, err := newConfig(node).forInterface("vnet3")
+
+			Convey("Overrides of preset are dropped", func() {
+				So(err, ShouldBeNil)
+				So(vnet.getBool("vm_tags", true), ShouldBeFalse)
+			})
+		})
+
+		Convey("When preset is unknown", func() {
+			node.AddItem("preset", ctypes.ConfigValueStr{Value: "mainframe"})
+			_, err := config(node.Table()).withPreset()
+
+			Convey("Error lists known presets", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "container-host hypervisor minimal router storage")
+			})
+
+			Convey("Config is used without preset", func() {
+				So(newConfig(node).getBool("fdb", false), ShouldBeFalse)
+				So(newConfig(node).getInt("max_interfaces", 0), ShouldEqual, 8)
+			})
+		})
+	})
+}
@@ -0,0 +1,141 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// procRoot is procfs tree of chroot, mounted VM image or container
+// reachable from the host, its metrics are published with name as source
+type procRoot struct {
+	name string
+	path string
+}
+
+// parseProcRoots parses comma separated list of roots in format name=path,
+// e.g. vm1=/mnt/vm1/proc,sidecar=/proc/1234/root/proc
+func parseProcRoots(s string) ([]procRoot, error) {
+	roots := []procRoot{}
+	err := parseNamed(s, "procfs root", func(name, path string) {
+		roots = append(roots, procRoot{name: name, path: path})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+// parseNamed parses comma separated list of items in format name=path
+// and passes them to add, what describes items in errors
+func parseNamed(s, what string, add func(name, path string)) error {
+	names := map[string]bool{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("Wrong %s format {%s}, expected name=path", what, item)
+		}
+		if names[kv[0]] {
+			return fmt.Errorf("Duplicated %s name {%s}", what, kv[0])
+		}
+		names[kv[0]] = true
+		add(kv[0], kv[1])
+	}
+	return nil
+}
+
+// stats reads interface statistics of the root
+func (r procRoot) stats() (map[string]interface{}, error) {
+	stats := map[string]interface{}{}
+	if err := readNetDev(filepath.Join(r.path, "net", "dev"), stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// namedStats are interface statistics of procfs root or network namespace,
+// they are published with name as metric source
+type namedStats struct {
+	name  string
+	stats map[string]interface{}
+}
+
+// parseNamedConfig returns procfs roots and network namespaces of config
+func parseNamedConfig(cfg config) ([]procRoot, []netnsTarget, error) {
+	roots, err := parseProcRoots(cfg.getString("proc_roots", ""))
+	if err != nil {
+		return nil, nil, err
+	}
+	namespaces, err := parseNetns(cfg.getString("netns", ""))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range roots {
+		for _, n := range namespaces {
+			if r.name == n.name {
+				return nil, nil, fmt.Errorf("Duplicated name {%s} of procfs root and network namespace", r.name)
+			}
+		}
+	}
+	return roots, namespaces, nil
+}
+
+// readNamedStats reads interface statistics of procfs roots and network
+// namespaces of config, ones which cannot be read are logged and skipped,
+// details adds link details to interfaces of network namespaces
+func (iface *ifacePlugin) readNamedStats(cfg config, details bool) ([]namedStats, error) {
+	roots, namespaces, err := parseNamedConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	named := []namedStats{}
+	for _, r := range roots {
+		rstats, err := r.stats()
+		if err != nil {
+			log.WithFields(log.Fields{"root": r.name}).Warn("Cannot read procfs root, ", err)
+			continue
+		}
+		named = append(named, namedStats{name: r.name, stats: rstats})
+	}
+	if _, ok := iface.disabled[netnsKey]; ok {
+		// missing capabilities are logged at start-up
+		return named, nil
+	}
+	for _, n := range namespaces {
+		nstats, err := n.stats(details)
+		if err != nil {
+			log.WithFields(log.Fields{"netns": n.name}).Warn("Cannot read network namespace, ", err)
+			continue
+		}
+		named = append(named, namedStats{name: n.name, stats: nstats})
+	}
+	return named, nil
+}
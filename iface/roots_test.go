@@ -0,0 +1,114 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestParseProcRoots(t *testing.T) {
+	Convey("Given list of procfs roots", t, func() {
+		Convey("When it is parsed", func() {
+			roots, err := parseProcRoots("vm1=/mnt/vm1/proc, sidecar=/proc/1234/root/proc")
+
+			Convey("Named roots are returned", func() {
+				So(err, ShouldBeNil)
+				So(roots, ShouldResemble, []procRoot{
+					{name: "vm1", path: "/mnt/vm1/proc"},
+					{name: "sidecar", path: "/proc/1234/root/proc"},
+				})
+			})
+		})
+
+		Convey("When root has no name", func() {
+			_, err := parseProcRoots("/mnt/vm1/proc")
+
+			Convey("Error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When root names are duplicated", func() {
+			_, err := parseProcRoots("vm1=/mnt/vm1/proc,vm1=/mnt/vm2/proc")
+
+			Convey("Error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestCollectProcRoots(t *testing.T) {
+	Convey("Given plugin configured with procfs roots", t, func() {
+		ifaceInfo = "../examples/test/proc.net.dev"
+		defer func() { ifaceInfo = "/proc/net/dev" }()
+		ifacePlg := &ifacePlugin{stats: map[string]interface{}{}, host: "hypervisor"}
+
+		node := cdata.NewNode()
+		node.AddItem("proc_roots", ctypes.ConfigValueStr{Value: "vm1=../examples/test/roots/vm1,sidecar=../examples/test/roots/sidecar,gone=../examples/test/roots/gone"})
+
+		Convey("When catalog is requested", func() {
+			mts, err := ifacePlg.GetMetricTypes(plugin.PluginConfigType{ConfigDataNode: node})
+			So(err, ShouldBeNil)
+
+			Convey("Interfaces of all roots are available", func() {
+				namespaces := []string{}
+				for _, m := range mts {
+					namespaces = append(namespaces, strings.Join(m.Namespace(), "/"))
+				}
+				So(len(mts), ShouldEqual, 48)
+				So(namespaces, ShouldContain, "intel/procfs/iface/p3p1/bytes_recv")
+				So(namespaces, ShouldContain, "intel/procfs/iface/eth0/bytes_recv")
+			})
+		})
+
+		Convey("When metrics are collected", func() {
+			mTypes := []plugin.PluginMetricType{
+				{Namespace_: []string{"intel", "procfs", "iface", "lo", "packets_recv"}, Config_: node},
+				{Namespace_: []string{"intel", "procfs", "iface", "eth0", "bytes_recv"}, Config_: node},
+			}
+			metrics, err := ifacePlg.CollectMetrics(mTypes)
+			So(err, ShouldBeNil)
+
+			values := map[string]interface{}{}
+			for _, m := range metrics {
+				values[m.Source_+":"+strings.Join(m.Namespace(), "/")] = m.Data_
+			}
+
+			Convey("Each root which has the interface is published as separate source", func() {
+				So(len(metrics), ShouldEqual, 5)
+				So(values["hypervisor:intel/procfs/iface/lo/packets_recv"], ShouldEqual, 18764106)
+				So(values["vm1:intel/procfs/iface/lo/packets_recv"], ShouldEqual, 120)
+				So(values["sidecar:intel/procfs/iface/lo/packets_recv"], ShouldEqual, 0)
+				So(values["vm1:intel/procfs/iface/eth0/bytes_recv"], ShouldEqual, 90210331)
+				So(values["sidecar:intel/procfs/iface/eth0/bytes_recv"], ShouldEqual, 523341)
+			})
+		})
+	})
+}
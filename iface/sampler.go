@@ -0,0 +1,586 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// sampleKey is namespace element under which sampled traffic breakdown is published
+	sampleKey = "_sample"
+)
+
+const (
+	ethPAll   = 0x0003
+	ethPIPv4  = 0x0800
+	ethPARP   = 0x0806
+	ethPIPv6  = 0x86dd
+	solPacket = 263
+
+	packetRxRing  = 5
+	packetVersion = 10
+	tpacketV2     = 1
+
+	tpStatusKernel = 0
+	tpStatusUser   = 1
+
+	// sizeof(struct tpacket2_hdr), sockaddr_ll follows it
+	tpacket2HdrLen = 32
+	packetOutgoing = 4
+	arphrdLoopback = 772
+
+	// skfAdRandom is BPF ancillary data offset returning random number
+	skfAdRandom = 0xfffff000 + 56
+	bpfMod      = 0x90
+
+	ringFrameSize = 256
+	ringBlockNr   = 64
+)
+
+var sampledProtocols = []string{"tcp", "udp", "icmp", "arp", "other"}
+
+// sampler is packet sampler shared between collections, it is restarted
+// when its settings change
+var sampler struct {
+	sync.Mutex
+	s *packetSampler
+}
+
+// counter holds estimated number of packets and bytes
+type counter struct {
+	packets uint64
+	bytes   uint64
+}
+
+// packetSampler reads every N-th packet from AF_PACKET ring buffer
+// and accounts it per interface, protocol and port
+type packetSampler struct {
+	fd        int
+	ring      []byte
+	frameNr   int
+	rate      int
+	filter    string
+	portsSpec string
+	// portsOf resolves config of interface with ports which are accounted separately
+	portsOf func(name string) config
+	// ports accounted separately per interface name, nil means all ports below 1024
+	ports map[string]map[uint16]bool
+
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu sync.Mutex
+	// counters are indexed by interface name and by protocol or protocol/port
+	counters map[string]map[string]*counter
+	names    map[int]string
+
+	last     map[string]map[string]counter
+	lastTime time.Time
+
+	// flows holds headers of sampled packets for sFlow export, nil when not kept
+	flows []flowHeader
+	// pool holds estimated number of packets seen per interface index
+	pool    map[int]uint32
+	dropped uint32
+}
+
+// getSampleStats starts packet sampler if needed and stores estimated traffic
+// rates observed since previous collection in stats map under sampleKey,
+// catalog and validation reads without state use short-lived sampler so that
+// they neither start sampling nor reset rates of collection
+func getSampleStats(stats map[string]interface{}, cfg config, state *sourceState) error {
+	rate := cfg.getInt("packet_sample_rate", 100)
+	filter := cfg.getString("packet_sample_filter", "")
+
+	if state == nil {
+		s, err := newPacketSampler(rate, filter, cfg)
+		if err != nil {
+			return err
+		}
+		defer s.close()
+		stats[sampleKey] = s.rates(now())
+		return nil
+	}
+
+	sampler.Lock()
+	defer sampler.Unlock()
+
+	if s := sampler.s; s == nil || s.rate != rate || s.filter != filter || s.portsSpec != cfg.itemSpec("packet_sample_ports") {
+		if s != nil {
+			s.close()
+			sampler.s = nil
+		}
+		s, err := newPacketSampler(rate, filter, cfg)
+		if err != nil {
+			return err
+		}
+		sampler.s = s
+	}
+
+	stats[sampleKey] = sampler.s.rates(now())
+	return nil
+}
+
+// newPacketSampler opens AF_PACKET socket with 1-in-rate sampling filter
+// optionally followed by user filter and starts reading its ring buffer,
+// packet_sample_ports of cfg and its overrides select ports of interfaces
+func newPacketSampler(rate int, filter string, cfg config) (*packetSampler, error) {
+	if rate < 1 {
+		return nil, fmt.Errorf("Wrong packet sampling rate {%d}", rate)
+	}
+	userFilter, err := parseBPF(filter)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := samplingFilter(rate, userFilter)
+	if err != nil {
+		return nil, err
+	}
+	portsOf, err := cfg.perInterface()
+	if err != nil {
+		return nil, err
+	}
+	for k := range cfg {
+		if strings.SplitN(k, overrideSep, 2)[0] != "packet_sample_ports" {
+			continue
+		}
+		if _, err := parsePorts(cfg.getString(k, "")); err != nil {
+			return nil, err
+		}
+	}
+
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, int(htons(ethPAll)))
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	s := &packetSampler{
+		fd:        fd,
+		rate:      rate,
+		filter:    filter,
+		portsSpec: cfg.itemSpec("packet_sample_ports"),
+		portsOf:   portsOf,
+		ports:     map[string]map[uint16]bool{},
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		counters:  map[string]map[string]*counter{},
+		names:     map[int]string{},
+		last:      map[string]map[string]counter{},
+		lastTime:  now(),
+	}
+	if err := s.setupRing(prog); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	go s.run()
+	return s, nil
+}
+
+func (s *packetSampler) setupRing(prog []syscall.SockFilter) error {
+	if err := syscall.AttachLsf(s.fd, prog); err != nil {
+		return os.NewSyscallError("setsockopt SO_ATTACH_FILTER", err)
+	}
+
+	version := uint32(tpacketV2)
+	if err := setsockopt(s.fd, solPacket, packetVersion, unsafe.Pointer(&version), unsafe.Sizeof(version)); err != nil {
+		return os.NewSyscallError("setsockopt PACKET_VERSION", err)
+	}
+
+	blockSize := os.Getpagesize()
+	req := struct {
+		blockSize uint32
+		blockNr   uint32
+		frameSize uint32
+		frameNr   uint32
+	}{
+		blockSize: uint32(blockSize),
+		blockNr:   ringBlockNr,
+		frameSize: ringFrameSize,
+		frameNr:   uint32(blockSize / ringFrameSize * ringBlockNr),
+	}
+	if err := setsockopt(s.fd, solPacket, packetRxRing, unsafe.Pointer(&req), unsafe.Sizeof(req)); err != nil {
+		return os.NewSyscallError("setsockopt PACKET_RX_RING", err)
+	}
+
+	ring, err := syscall.Mmap(s.fd, 0, blockSize*ringBlockNr, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return os.NewSyscallError("mmap", err)
+	}
+	s.ring = ring
+	s.frameNr = int(req.frameNr)
+	return nil
+}
+
+// run hands ring frames over from the kernel, accounts them and returns them back
+func (s *packetSampler) run() {
+	defer close(s.stopped)
+
+	for frame := 0; ; {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		hdr := s.ring[frame*ringFrameSize : (frame+1)*ringFrameSize]
+		status := (*uint32)(unsafe.Pointer(&hdr[0]))
+		if atomic.LoadUint32(status)&tpStatusUser == 0 {
+			if err := poll(s.fd, 100*time.Millisecond); err != nil && err != syscall.EINTR {
+				log.WithFields(log.Fields{"source": sampleKey}).Error("Packet sampler stopped, ", err)
+				return
+			}
+			continue
+		}
+
+		s.account(hdr)
+		atomic.StoreUint32(status, tpStatusKernel)
+		frame = (frame + 1) % s.frameNr
+	}
+}
+
+// account decodes tpacket2_hdr frame and updates counters of its interface
+func (s *packetSampler) account(hdr []byte) {
+	length := nativeEndian.Uint32(hdr[4:8])
+	snaplen := int(nativeEndian.Uint32(hdr[8:12]))
+	mac := int(nativeEndian.Uint16(hdr[12:14]))
+	netOff := int(nativeEndian.Uint16(hdr[14:16]))
+
+	sll := hdr[tpacket2HdrLen:]
+	ethertype := binary.BigEndian.Uint16(sll[2:4])
+	ifindex := int(int32(nativeEndian.Uint32(sll[4:8])))
+	hatype := nativeEndian.Uint16(sll[8:10])
+	pkttype := sll[10]
+
+	// loopback devices see every packet twice, as outgoing and as incoming
+	if hatype == arphrdLoopback && pkttype == packetOutgoing {
+		return
+	}
+
+	end := mac + snaplen
+	if end > len(hdr) {
+		end = len(hdr)
+	}
+	l3 := []byte{}
+	if netOff < end {
+		l3 = hdr[netOff:end]
+	}
+
+	proto, port, hasPort := classifyPacket(ethertype, l3)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.flows != nil {
+		s.keepFlow(ifindex, pkttype == packetOutgoing, length, hdr, mac, netOff, end, ethertype)
+	}
+
+	name := s.ifaceName(ifindex)
+	counters, ok := s.counters[name]
+	if !ok {
+		counters = map[string]*counter{}
+		s.counters[name] = counters
+	}
+	s.count(counters, proto, length)
+	if hasPort && s.trackPort(name, port) {
+		s.count(counters, proto+"/"+strconv.Itoa(int(port)), length)
+	}
+}
+
+func (s *packetSampler) count(counters map[string]*counter, key string, length uint32) {
+	c, ok := counters[key]
+	if !ok {
+		c = &counter{}
+		counters[key] = c
+	}
+	c.packets += uint64(s.rate)
+	c.bytes += uint64(length) * uint64(s.rate)
+}
+
+func (s *packetSampler) trackPort(name string, port uint16) bool {
+	ports, ok := s.ports[name]
+	if !ok {
+		// ports were checked when sampler was created
+		ports, _ = parsePorts(s.portsOf(name).getString("packet_sample_ports", ""))
+		s.ports[name] = ports
+	}
+	if ports == nil {
+		return port < 1024
+	}
+	return ports[port]
+}
+
+func (s *packetSampler) ifaceName(ifindex int) string {
+	if name, ok := s.names[ifindex]; ok {
+		return name
+	}
+	name := strconv.Itoa(ifindex)
+	if i, err := net.InterfaceByIndex(ifindex); err == nil {
+		name = i.Name
+	}
+	s.names[ifindex] = name
+	return name
+}
+
+// snapshot returns copy of estimated counters
+func (s *packetSampler) snapshot() map[string]map[string]counter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := map[string]map[string]counter{}
+	for name, counters := range s.counters {
+		snap[name] = map[string]counter{}
+		for key, c := range counters {
+			snap[name][key] = *c
+		}
+	}
+	return snap
+}
+
+// rates returns packet and byte rates per interface, protocol and port
+// observed since previous call, protocols of all known interfaces are
+// always present so they can be listed in metric catalog
+func (s *packetSampler) rates(now time.Time) map[string]interface{} {
+	cur := s.snapshot()
+	elapsed := now.Sub(s.lastTime).Seconds()
+
+	rates := map[string]interface{}{}
+	if ifaces, err := net.Interfaces(); err == nil {
+		for _, i := range ifaces {
+			protos := map[string]interface{}{}
+			for _, proto := range sampledProtocols {
+				protos[proto] = map[string]interface{}{"packets_rate": 0.0, "bytes_rate": 0.0}
+			}
+			rates[i.Name] = protos
+		}
+	}
+
+	for name, counters := range cur {
+		protos, ok := rates[name].(map[string]interface{})
+		if !ok {
+			protos = map[string]interface{}{}
+			rates[name] = protos
+		}
+		for key, c := range counters {
+			prev := s.last[name][key]
+			r := map[string]interface{}{"packets_rate": 0.0, "bytes_rate": 0.0}
+			if elapsed > 0 {
+				r["packets_rate"] = float64(c.packets-prev.packets) / elapsed
+				r["bytes_rate"] = float64(c.bytes-prev.bytes) / elapsed
+			}
+
+			parts := strings.SplitN(key, "/", 2)
+			proto, ok := protos[parts[0]].(map[string]interface{})
+			if !ok {
+				proto = map[string]interface{}{}
+				protos[parts[0]] = proto
+			}
+			if len(parts) == 1 {
+				proto["packets_rate"] = r["packets_rate"]
+				proto["bytes_rate"] = r["bytes_rate"]
+				continue
+			}
+			ports, ok := proto["port"].(map[string]interface{})
+			if !ok {
+				ports = map[string]interface{}{}
+				proto["port"] = ports
+			}
+			ports[parts[1]] = r
+		}
+	}
+
+	s.last = cur
+	s.lastTime = now
+	return rates
+}
+
+func (s *packetSampler) close() {
+	close(s.done)
+	<-s.stopped
+	syscall.Munmap(s.ring)
+	syscall.Close(s.fd)
+}
+
+// classifyPacket returns protocol of packet with given network header
+// and service port, which is the lower one of source and destination ports
+func classifyPacket(ethertype uint16, l3 []byte) (string, uint16, bool) {
+	var proto byte
+	var l4 []byte
+
+	switch ethertype {
+	case ethPARP:
+		return "arp", 0, false
+	case ethPIPv4:
+		if len(l3) < 20 {
+			return "other", 0, false
+		}
+		proto = l3[9]
+		if ihl := int(l3[0]&0x0f) * 4; ihl <= len(l3) {
+			l4 = l3[ihl:]
+		}
+		// only first fragment carries transport header
+		if binary.BigEndian.Uint16(l3[6:8])&0x1fff != 0 {
+			l4 = nil
+		}
+	case ethPIPv6:
+		if len(l3) < 40 {
+			return "other", 0, false
+		}
+		proto = l3[6]
+		l4 = l3[40:]
+	default:
+		return "other", 0, false
+	}
+
+	switch proto {
+	case syscall.IPPROTO_ICMP, syscall.IPPROTO_ICMPV6:
+		return "icmp", 0, false
+	case syscall.IPPROTO_TCP, syscall.IPPROTO_UDP:
+		name := "tcp"
+		if proto == syscall.IPPROTO_UDP {
+			name = "udp"
+		}
+		if len(l4) < 4 {
+			return name, 0, false
+		}
+		src := binary.BigEndian.Uint16(l4[0:2])
+		dst := binary.BigEndian.Uint16(l4[2:4])
+		if src < dst {
+			return name, src, true
+		}
+		return name, dst, true
+	}
+	return "other", 0, false
+}
+
+// parseBPF parses classic BPF program in `tcpdump -ddd` format with lines
+// joined by commas, e.g. "4,40 0 0 12,21 0 1 2048,6 0 0 65535,6 0 0 0"
+func parseBPF(s string) ([]syscall.SockFilter, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(s, ",")
+	n, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return nil, fmt.Errorf("Wrong BPF program length {%s}", fields[0])
+	}
+	if n != len(fields)-1 {
+		return nil, fmt.Errorf("Wrong BPF program length. Expected {%d} is {%d}", n, len(fields)-1)
+	}
+
+	prog := make([]syscall.SockFilter, n)
+	for i, ins := range fields[1:] {
+		f := strings.Fields(ins)
+		if len(f) != 4 {
+			return nil, fmt.Errorf("Wrong BPF instruction format {%s}", ins)
+		}
+		vals := make([]uint64, 4)
+		for j, bits := range []int{16, 8, 8, 32} {
+			if vals[j], err = strconv.ParseUint(f[j], 10, bits); err != nil {
+				return nil, fmt.Errorf("Wrong BPF instruction format {%s}", ins)
+			}
+		}
+		prog[i] = syscall.SockFilter{Code: uint16(vals[0]), Jt: uint8(vals[1]), Jf: uint8(vals[2]), K: uint32(vals[3])}
+	}
+	return prog, nil
+}
+
+// samplingFilter prepends 1-in-rate random sampling to user filter,
+// user filter is replaced by accept-all program when empty
+func samplingFilter(rate int, user []syscall.SockFilter) ([]syscall.SockFilter, error) {
+	if len(user) == 0 {
+		user = []syscall.SockFilter{{Code: syscall.BPF_RET | syscall.BPF_K, K: 0xffff}}
+	}
+	if rate == 1 {
+		return user, nil
+	}
+	if len(user) > 255 {
+		return nil, fmt.Errorf("BPF filter is too long {%d}", len(user))
+	}
+
+	prog := []syscall.SockFilter{
+		{Code: syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS, K: skfAdRandom},
+		{Code: syscall.BPF_ALU | bpfMod | syscall.BPF_K, K: uint32(rate)},
+		{Code: syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K, Jf: uint8(len(user)), K: 0},
+	}
+	prog = append(prog, user...)
+	return append(prog, syscall.SockFilter{Code: syscall.BPF_RET | syscall.BPF_K, K: 0}), nil
+}
+
+// parsePorts parses comma separated list of ports, empty list results in nil
+func parsePorts(s string) (map[uint16]bool, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	ports := map[uint16]bool{}
+	for _, p := range strings.Split(s, ",") {
+		port, err := strconv.ParseUint(strings.TrimSpace(p), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("Wrong port {%s}", p)
+		}
+		ports[uint16(port)] = true
+	}
+	return ports, nil
+}
+
+func htons(v uint16) uint16 {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return nativeEndian.Uint16(b)
+}
+
+func setsockopt(fd, level, opt int, val unsafe.Pointer, size uintptr) error {
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(fd), uintptr(level), uintptr(opt), uintptr(val), size, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// poll waits until fd is readable or timeout expires
+func poll(fd int, timeout time.Duration) error {
+	pfd := struct {
+		fd      int32
+		events  int16
+		revents int16
+	}{fd: int32(fd), events: 0x1}
+	ts := syscall.NsecToTimespec(timeout.Nanoseconds())
+	_, _, errno := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(unsafe.Pointer(&pfd)), 1, uintptr(unsafe.Pointer(&ts)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+const netnsTestEnv = "IFACE_TEST_NETNS"
+
+// runInNetns re-executes test of given name inside new unprivileged user,
+// network and mount namespace, it returns true when called within that namespace
+func runInNetns(t *testing.T, name string) bool {
+	if os.Getenv(netnsTestEnv) == name {
+		return true
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^"+name+"$", "-test.v")
+	cmd.Env = append(os.Environ(), netnsTestEnv+"="+name)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET | syscall.CLONE_NEWNS,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+	}
+
+	out, err := cmd.CombinedOutput()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		t.Skip("Unprivileged user and network namespaces are not available, ", err)
+	}
+	if err != nil {
+		t.Fatalf("Test within network namespace failed: %v\n%s", err, out)
+	}
+	return false
+}
+
+// setLinkUp brings up interface of given name
+func setLinkUp(name string) error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	ifr := struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte
+	}{}
+	copy(ifr.name[:], name)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return errno
+	}
+	ifr.flags |= syscall.IFF_UP
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func TestClassifyPacket(t *testing.T) {
+	Convey("Given network headers of different protocols", t, func() {
+		udp := make([]byte, 28)
+		udp[0] = 0x45
+		udp[9] = syscall.IPPROTO_UDP
+		udp[20], udp[21] = 0xd4, 0x31 // 54321
+		udp[22], udp[23] = 0x00, 0x35 // 53
+
+		tcp6 := make([]byte, 60)
+		tcp6[6] = syscall.IPPROTO_TCP
+		tcp6[40], tcp6[41] = 0x01, 0xbb // 443
+		tcp6[42], tcp6[43] = 0xc3, 0x50 // 50000
+
+		icmp := make([]byte, 28)
+		icmp[0] = 0x45
+		icmp[9] = syscall.IPPROTO_ICMP
+
+		Convey("Protocol and service port are recognized", func() {
+			proto, port, ok := classifyPacket(ethPIPv4, udp)
+			So(proto, ShouldEqual, "udp")
+			So(port, ShouldEqual, 53)
+			So(ok, ShouldBeTrue)
+
+			proto, port, ok = classifyPacket(ethPIPv6, tcp6)
+			So(proto, ShouldEqual, "tcp")
+			So(port, ShouldEqual, 443)
+			So(ok, ShouldBeTrue)
+
+			proto, _, ok = classifyPacket(ethPIPv4, icmp)
+			So(proto, ShouldEqual, "icmp")
+			So(ok, ShouldBeFalse)
+
+			proto, _, _ = classifyPacket(ethPARP, nil)
+			So(proto, ShouldEqual, "arp")
+
+			proto, _, _ = classifyPacket(0x88cc, nil)
+			So(proto, ShouldEqual, "other")
+		})
+
+		Convey("Truncated headers are not reported with port", func() {
+			proto, _, ok := classifyPacket(ethPIPv4, udp[:22])
+			So(proto, ShouldEqual, "udp")
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+func TestParseBPF(t *testing.T) {
+	Convey("Given BPF program in tcpdump -ddd format", t, func() {
+		prog, err := parseBPF("4,40 0 0 12,21 0 1 2048,6 0 0 65535,6 0 0 0")
+
+		Convey("Instructions are parsed", func() {
+			So(err, ShouldBeNil)
+			So(len(prog), ShouldEqual, 4)
+			So(prog[1], ShouldResemble, syscall.SockFilter{Code: 21, Jt: 0, Jf: 1, K: 2048})
+		})
+
+		Convey("Sampling is prepended and jumps over user filter", func() {
+			sampled, err := samplingFilter(10, prog)
+			So(err, ShouldBeNil)
+			So(len(sampled), ShouldEqual, 8)
+			So(sampled[1].K, ShouldEqual, 10)
+			So(int(sampled[2].Jf), ShouldEqual, len(prog))
+			So(sampled[len(sampled)-1].K, ShouldEqual, 0)
+		})
+
+		Convey("Wrong programs are rejected", func() {
+			_, err := parseBPF("3,40 0 0 12")
+			So(err, ShouldNotBeNil)
+			_, err = parseBPF("1,40 0 12")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestTrackPort(t *testing.T) {
+	Convey("Given sampler with ports of uplinks overridden", t, func() {
+		cfg := config{
+			"packet_sample_ports":     ctypes.ConfigValueStr{Value: "53,8080"},
+			"packet_sample_ports@^lo": ctypes.ConfigValueStr{Value: ""},
+		}
+		portsOf, err := cfg.perInterface()
+		So(err, ShouldBeNil)
+		s := &packetSampler{portsOf: portsOf, ports: map[string]map[uint16]bool{}}
+
+		Convey("Ports of interface config are accounted separately", func() {
+			So(s.trackPort("eth0", 8080), ShouldBeTrue)
+			So(s.trackPort("eth0", 22), ShouldBeFalse)
+			So(s.trackPort("lo", 22), ShouldBeTrue)
+			So(s.trackPort("lo", 8080), ShouldBeFalse)
+		})
+	})
+}
+
+func TestPacketSamplerNetns(t *testing.T) {
+	if !runInNetns(t, "TestPacketSamplerNetns") {
+		return
+	}
+
+	Convey("Given packet sampler running in network namespace", t, func() {
+		So(setLinkUp("lo"), ShouldBeNil)
+
+		s, err := newPacketSampler(1, "", config{})
+		So(err, ShouldBeNil)
+		defer s.close()
+
+		Convey("When known number of udp packets is sent over loopback", func() {
+			rcv, err := net.ListenPacket("udp4", "127.0.0.1:514")
+			So(err, ShouldBeNil)
+			defer rcv.Close()
+
+			snd, err := net.Dial("udp4", "127.0.0.1:514")
+			So(err, ShouldBeNil)
+			defer snd.Close()
+
+			payload := make([]byte, 100)
+			for i := 0; i < 10; i++ {
+				_, err := snd.Write(payload)
+				So(err, ShouldBeNil)
+			}
+
+			var c counter
+			for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				if c = s.snapshot()["lo"]["udp/514"]; c.packets >= 10 {
+					break
+				}
+			}
+
+			Convey("Sampler accounts them to protocol and port of loopback", func() {
+				So(c.packets, ShouldEqual, 10)
+				So(c.bytes, ShouldEqual, 10*(14+20+8+100))
+				So(s.snapshot()["lo"]["udp"].packets, ShouldEqual, 10)
+			})
+
+			Convey("Rates are published per interface, protocol and port", func() {
+				rates := s.rates(time.Now())
+				udp := rates["lo"].(map[string]interface{})["udp"].(map[string]interface{})
+				So(udp["packets_rate"], ShouldBeGreaterThan, 0)
+				port := udp["port"].(map[string]interface{})["514"].(map[string]interface{})
+				So(port["bytes_rate"], ShouldBeGreaterThan, 0)
+			})
+		})
+	})
+}
+
+func TestGetSampleStatsNetns(t *testing.T) {
+	if !runInNetns(t, "TestGetSampleStatsNetns") {
+		return
+	}
+
+	Convey("Given packet sampling config in network namespace", t, func() {
+		So(setLinkUp("lo"), ShouldBeNil)
+		cfg := config{"packet_sample_rate": ctypes.ConfigValueInt{Value: 1}}
+
+		Convey("When stats are read without state as by catalog or validate", func() {
+			stats := map[string]interface{}{}
+			err := getSampleStats(stats, cfg, nil)
+
+			Convey("Rates of interfaces are published without starting sampler", func() {
+				So(err, ShouldBeNil)
+				So(stats[sampleKey].(map[string]interface{}), ShouldContainKey, "lo")
+				So(sampler.s, ShouldBeNil)
+			})
+		})
+
+		Convey("When stats are collected", func() {
+			err := getSampleStats(map[string]interface{}{}, cfg, &sourceState{})
+			defer func() {
+				if sampler.s != nil {
+					sampler.s.close()
+					sampler.s = nil
+				}
+			}()
+
+			Convey("Sampler keeps running for next collection", func() {
+				So(err, ShouldBeNil)
+				So(sampler.s, ShouldNotBeNil)
+				So(sampler.s.rate, ShouldEqual, 1)
+			})
+		})
+	})
+}
@@ -0,0 +1,260 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/intelsdi-x/snap-plugin-collector-interface/sflow"
+)
+
+const (
+	// maxFlows bounds number of packet headers kept between exports
+	maxFlows = 1024
+	// maxFlowHeader bounds length of kept packet header
+	maxFlowHeader = 128
+
+	ifTypeOther            = 1
+	ifTypeEthernetCsmacd   = 6
+	ifTypeSoftwareLoopback = 24
+	arphrdEther            = 1
+)
+
+var sysClassNet = "/sys/class/net"
+
+// flowHeader is header of single sampled packet kept for sFlow export
+type flowHeader struct {
+	ifindex  int
+	outgoing bool
+	proto    uint32
+	length   uint32
+	data     []byte
+}
+
+// SFlowCounters returns sFlow generic interface counters built
+// from statistics of all interfaces which are present on the host
+func SFlowCounters() ([]sflow.IfCounters, error) {
+	stats := map[string]interface{}{}
+	if err := getStats(stats, statsBackendProcfs); err != nil {
+		return nil, err
+	}
+
+	counters := []sflow.IfCounters{}
+	for name, istats := range stats {
+		i, err := net.InterfaceByName(name)
+		if err != nil {
+			continue
+		}
+		counters = append(counters, ifCounters(i, istats.(map[string]interface{})))
+	}
+	sort.Sort(byIndex(counters))
+	return counters, nil
+}
+
+type byIndex []sflow.IfCounters
+
+func (s byIndex) Len() int           { return len(s) }
+func (s byIndex) Less(i, j int) bool { return s[i].Index < s[j].Index }
+func (s byIndex) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// ifCounters maps interface statistics and its sysfs attributes to sFlow counters
+func ifCounters(i *net.Interface, istats map[string]interface{}) sflow.IfCounters {
+	stat := func(name string) int64 {
+		if v, ok := istats[name].(int64); ok && v >= 0 {
+			return v
+		}
+		return -1
+	}
+	counter32 := func(v int64) uint32 {
+		if v < 0 {
+			return sflow.Unknown
+		}
+		return uint32(v)
+	}
+	counter64 := func(v int64) uint64 {
+		if v < 0 {
+			return 0
+		}
+		return uint64(v)
+	}
+	ucast := func(packets, multicast int64) uint32 {
+		if packets < 0 || multicast < 0 || multicast > packets {
+			return counter32(packets)
+		}
+		return uint32(packets - multicast)
+	}
+
+	c := sflow.IfCounters{
+		Index:            uint32(i.Index),
+		Type:             ifTypeOther,
+		InOctets:         counter64(stat("bytes_recv")),
+		InUcastPkts:      ucast(stat("packets_recv"), stat("multicast_recv")),
+		InMulticastPkts:  counter32(stat("multicast_recv")),
+		InBroadcastPkts:  sflow.Unknown,
+		InDiscards:       counter32(stat("drop_recv")),
+		InErrors:         counter32(stat("errs_recv")),
+		InUnknownProtos:  sflow.Unknown,
+		OutOctets:        counter64(stat("bytes_sent")),
+		OutUcastPkts:     counter32(stat("packets_sent")),
+		OutMulticastPkts: sflow.Unknown,
+		OutBroadcastPkts: sflow.Unknown,
+		OutDiscards:      counter32(stat("drop_sent")),
+		OutErrors:        counter32(stat("errs_sent")),
+	}
+
+	switch readSysfsInt(i.Name, "type", 10) {
+	case arphrdEther:
+		c.Type = ifTypeEthernetCsmacd
+	case arphrdLoopback:
+		c.Type = ifTypeSoftwareLoopback
+	}
+	if speed := readSysfsInt(i.Name, "speed", 10); speed > 0 {
+		c.Speed = uint64(speed) * 1000000
+	}
+	switch readSysfs(i.Name, "duplex") {
+	case "full":
+		c.Direction = 1
+	case "half":
+		c.Direction = 2
+	}
+	flags := readSysfsInt(i.Name, "flags", 0)
+	if flags >= 0 && flags&syscall.IFF_UP != 0 {
+		c.Status |= 1
+	}
+	if flags >= 0 && flags&syscall.IFF_PROMISC != 0 {
+		c.PromiscuousMode = 1
+	}
+	if readSysfs(i.Name, "operstate") == "up" {
+		c.Status |= 2
+	}
+	return c
+}
+
+// readSysfs returns trimmed content of interface attribute, empty when not available
+func readSysfs(iface, attr string) string {
+	b, err := ioutil.ReadFile(filepath.Join(sysClassNet, iface, attr))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// readSysfsInt returns numeric interface attribute, -1 when not available
+func readSysfsInt(iface, attr string, base int) int64 {
+	v, err := strconv.ParseInt(readSysfs(iface, attr), base, 64)
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+// FlowSampler samples packets of all interfaces for sFlow flow samples
+type FlowSampler struct {
+	s *packetSampler
+}
+
+// NewFlowSampler starts sampling 1 in rate packets matching optional BPF filter
+func NewFlowSampler(rate int, filter string) (*FlowSampler, error) {
+	s, err := newPacketSampler(rate, filter, config{})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.flows = []flowHeader{}
+	s.pool = map[int]uint32{}
+	s.mu.Unlock()
+	return &FlowSampler{s: s}, nil
+}
+
+// Flows returns flow samples of packets sampled since previous call
+func (f *FlowSampler) Flows() []sflow.FlowSample {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+
+	flows := make([]sflow.FlowSample, 0, len(f.s.flows))
+	for _, h := range f.s.flows {
+		sample := sflow.FlowSample{
+			SourceIndex:    uint32(h.ifindex),
+			SamplingRate:   uint32(f.s.rate),
+			SamplePool:     f.s.pool[h.ifindex],
+			Drops:          f.s.dropped,
+			HeaderProtocol: h.proto,
+			FrameLength:    h.length,
+			Header:         h.data,
+		}
+		if h.outgoing {
+			sample.Output = uint32(h.ifindex)
+		} else {
+			sample.Input = uint32(h.ifindex)
+		}
+		flows = append(flows, sample)
+	}
+	f.s.flows = f.s.flows[:0]
+	return flows
+}
+
+// Close stops sampling
+func (f *FlowSampler) Close() {
+	f.s.close()
+}
+
+// keepFlow stores header of sampled frame, it is called with sampler lock held
+func (s *packetSampler) keepFlow(ifindex int, outgoing bool, length uint32, hdr []byte, mac, netOff, end int, ethertype uint16) {
+	s.pool[ifindex] += uint32(s.rate)
+	if len(s.flows) >= maxFlows {
+		s.dropped++
+		return
+	}
+
+	var proto uint32
+	start := netOff
+	switch {
+	case netOff-mac >= 14:
+		proto, start = sflow.HeaderEthernet, mac
+	case ethertype == ethPIPv4:
+		proto = sflow.HeaderIPv4
+	case ethertype == ethPIPv6:
+		proto = sflow.HeaderIPv6
+	default:
+		return
+	}
+	if start >= end {
+		return
+	}
+	if end-start > maxFlowHeader {
+		end = start + maxFlowHeader
+	}
+
+	s.flows = append(s.flows, flowHeader{
+		ifindex:  ifindex,
+		outgoing: outgoing,
+		proto:    proto,
+		length:   length,
+		data:     append([]byte(nil), hdr[start:end]...),
+	})
+}
@@ -0,0 +1,123 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-collector-interface/sflow"
+)
+
+func TestIfCounters(t *testing.T) {
+	Convey("Given interface statistics and its sysfs attributes", t, func() {
+		sysClassNet = "../examples/test/sys/class/net"
+		defer func() { sysClassNet = "/sys/class/net" }()
+
+		istats := map[string]interface{}{
+			"bytes_recv":     int64(1412848320),
+			"packets_recv":   int64(12238775),
+			"multicast_recv": int64(775),
+			"drop_recv":      int64(3),
+			"errs_recv":      int64(-1),
+			"bytes_sent":     int64(6434234393),
+			"packets_sent":   int64(17015516),
+		}
+
+		Convey("When they are mapped to sFlow counters", func() {
+			c := ifCounters(&net.Interface{Index: 7, Name: "p3p1"}, istats)
+
+			Convey("Generic interface counters are filled", func() {
+				So(c.Index, ShouldEqual, 7)
+				So(c.Type, ShouldEqual, ifTypeEthernetCsmacd)
+				So(c.Speed, ShouldEqual, uint64(10000000000))
+				So(c.Direction, ShouldEqual, 1)
+				So(c.Status, ShouldEqual, 3)
+				So(c.PromiscuousMode, ShouldEqual, 1)
+				So(c.InOctets, ShouldEqual, uint64(1412848320))
+				So(c.InUcastPkts, ShouldEqual, 12238000)
+				So(c.InMulticastPkts, ShouldEqual, 775)
+				So(c.InDiscards, ShouldEqual, 3)
+				So(c.OutOctets, ShouldEqual, uint64(6434234393))
+				So(c.OutUcastPkts, ShouldEqual, 17015516)
+			})
+
+			Convey("Counters which are not available are unknown", func() {
+				So(c.InErrors, ShouldEqual, sflow.Unknown)
+				So(c.InBroadcastPkts, ShouldEqual, sflow.Unknown)
+				So(c.OutErrors, ShouldEqual, sflow.Unknown)
+			})
+		})
+	})
+}
+
+func TestFlowSamplerNetns(t *testing.T) {
+	if !runInNetns(t, "TestFlowSamplerNetns") {
+		return
+	}
+
+	Convey("Given flow sampler running in network namespace", t, func() {
+		So(setLinkUp("lo"), ShouldBeNil)
+		lo, err := net.InterfaceByName("lo")
+		So(err, ShouldBeNil)
+
+		f, err := NewFlowSampler(1, "")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		Convey("When udp packets are sent over loopback", func() {
+			rcv, err := net.ListenPacket("udp4", "127.0.0.1:514")
+			So(err, ShouldBeNil)
+			defer rcv.Close()
+
+			snd, err := net.Dial("udp4", "127.0.0.1:514")
+			So(err, ShouldBeNil)
+			defer snd.Close()
+
+			for i := 0; i < 5; i++ {
+				_, err := snd.Write(make([]byte, 100))
+				So(err, ShouldBeNil)
+			}
+
+			flows := []sflow.FlowSample{}
+			for deadline := time.Now().Add(2 * time.Second); len(flows) < 5 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				flows = append(flows, f.Flows()...)
+			}
+
+			Convey("Their headers are returned as flow samples", func() {
+				So(len(flows), ShouldEqual, 5)
+				for _, flow := range flows {
+					So(flow.SourceIndex, ShouldEqual, lo.Index)
+					So(flow.Input, ShouldEqual, lo.Index)
+					So(flow.SamplingRate, ShouldEqual, 1)
+					So(flow.HeaderProtocol, ShouldEqual, sflow.HeaderEthernet)
+					So(flow.FrameLength, ShouldEqual, 14+20+8+100)
+					So(len(flow.Header), ShouldEqual, maxFlowHeader)
+				}
+				So(flows[4].SamplePool, ShouldEqual, 5)
+			})
+		})
+	})
+}
@@ -0,0 +1,177 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-collector-interface/procsim"
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// simulate runs scenario and collects requested metrics after each of its
+// steps, it returns values and timestamps indexed by step and namespace
+func simulate(script string, node *cdata.ConfigDataNode, namespaces ...string) ([]map[string]interface{}, []time.Time) {
+	dir, err := ioutil.TempDir("", "iface")
+	So(err, ShouldBeNil)
+	defer os.RemoveAll(dir)
+	sim, err := procsim.New(dir, time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC))
+	So(err, ShouldBeNil)
+	So(sim.Load(script), ShouldBeNil)
+
+	ifaceInfo, sysClassNet, now = sim.ProcNetDev(), sim.SysClassNet(), sim.Now
+	defer func() { ifaceInfo, sysClassNet, now = "/proc/net/dev", "/sys/class/net", time.Now }()
+
+	mTypes := []plugin.PluginMetricType{}
+	for _, ns := range namespaces {
+		mTypes = append(mTypes, plugin.PluginMetricType{Namespace_: strings.Split("intel/procfs/iface/"+ns, "/"), Config_: node})
+	}
+
+	ifacePlg := &ifacePlugin{stats: map[string]interface{}{}}
+	values := []map[string]interface{}{}
+	times := []time.Time{}
+	for {
+		ok, err := sim.Next()
+		So(err, ShouldBeNil)
+		if !ok {
+			break
+		}
+		metrics, err := ifacePlg.CollectMetrics(mTypes)
+		So(err, ShouldBeNil)
+		step := map[string]interface{}{}
+		for _, m := range metrics {
+			step[strings.Join(m.Namespace()[3:], "/")] = m.Data_
+		}
+		values = append(values, step)
+		times = append(times, metrics[0].Timestamp())
+	}
+	return values, times
+}
+
+func TestSimulatedCounters(t *testing.T) {
+	Convey("Given interface with 32-bit counters", t, func() {
+		script := `
+			add eth0
+			width eth0 32
+			rate eth0 bytes_recv=200000000
+			set eth0 bytes_recv=4000000000
+			collect
+			advance 2s
+			collect
+			reset eth0
+			advance 1s
+			collect
+		`
+
+		Convey("When metrics are collected", func() {
+			values, times := simulate(script, cdata.NewNode(), "eth0/bytes_recv")
+
+			Convey("Metrics are timestamped by collection clock", func() {
+				So(times[1].Sub(times[0]), ShouldEqual, 2*time.Second)
+				So(times[2].Sub(times[1]), ShouldEqual, time.Second)
+			})
+
+			Convey("Wrapped and reset counters are published as read", func() {
+				So(values[0]["eth0/bytes_recv"], ShouldEqual, 4000000000)
+				So(values[1]["eth0/bytes_recv"], ShouldEqual, 4400000000-(1<<32))
+				So(values[2]["eth0/bytes_recv"], ShouldEqual, 200000000)
+			})
+		})
+	})
+}
+
+func TestSimulatedChurn(t *testing.T) {
+	Convey("Given interfaces which are renamed and removed", t, func() {
+		script := `
+			add eth0
+			add veth1
+			rate eth0 packets_sent=10
+			advance 1s
+			collect
+			rename eth0 ens3
+			del veth1
+			advance 1s
+			collect
+		`
+
+		Convey("When metrics are collected", func() {
+			values, _ := simulate(script, cdata.NewNode(), "eth0/packets_sent", "ens3/packets_sent", "veth1/packets_sent")
+
+			Convey("Metrics of interfaces which disappeared have no value", func() {
+				So(values[0]["eth0/packets_sent"], ShouldEqual, 10)
+				So(values[0]["veth1/packets_sent"], ShouldEqual, 0)
+				So(values[1]["eth0/packets_sent"], ShouldBeNil)
+				So(values[1]["veth1/packets_sent"], ShouldBeNil)
+			})
+
+			Convey("Renamed interface keeps its counters", func() {
+				So(values[0]["ens3/packets_sent"], ShouldBeNil)
+				So(values[1]["ens3/packets_sent"], ShouldEqual, 20)
+			})
+		})
+	})
+
+	Convey("Given limited number of interfaces and appearing interfaces", t, func() {
+		node := cdata.NewNode()
+		node.AddItem("max_interfaces", ctypes.ConfigValueInt{Value: 2})
+		script := `
+			add veth9
+			add lo
+			rate veth9 bytes_recv=1
+			rate lo bytes_recv=2
+			advance 1s
+			collect
+			add eth0
+			rate eth0 bytes_recv=4
+			advance 1s
+			collect
+			del lo
+			advance 1s
+			collect
+		`
+
+		Convey("When metrics are collected", func() {
+			values, _ := simulate(script, node, "veth9/bytes_recv", "eth0/bytes_recv", "_overflow/interfaces", "_overflow/bytes_recv")
+
+			Convey("Interfaces published before are kept", func() {
+				So(values[1]["veth9/bytes_recv"], ShouldEqual, 2)
+				So(values[1]["eth0/bytes_recv"], ShouldBeNil)
+				So(values[1]["_overflow/interfaces"], ShouldEqual, 1)
+				So(values[1]["_overflow/bytes_recv"], ShouldEqual, 4)
+			})
+
+			Convey("Appeared interface is published when there is room", func() {
+				So(values[2]["eth0/bytes_recv"], ShouldEqual, 8)
+				So(values[2]["_overflow/interfaces"], ShouldEqual, 0)
+				So(values[2]["_overflow/bytes_recv"], ShouldEqual, 0)
+			})
+		})
+	})
+}
@@ -0,0 +1,323 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"syscall"
+)
+
+const (
+	// tcpKey is namespace element under which tcp connection quality is published
+	tcpKey = "_tcp"
+
+	// aggregateByPort groups connections by local port
+	aggregateByPort = "port"
+	// aggregateBySubnet groups connections by remote subnet
+	aggregateBySubnet = "subnet"
+	// ephemeralKey is port group of connections which local port has no
+	// listening socket, e.g. client side of outbound connections
+	ephemeralKey = "ephemeral"
+)
+
+const (
+	netlinkSockDiag  = 4
+	sockDiagByFamily = 20
+	inetDiagInfo     = 2
+	tcpEstablished   = 1
+	tcpListen        = 10
+
+	sizeofInetDiagReqV2 = 56
+	sizeofInetDiagMsg   = 72
+
+	// offsets of used fields within struct tcp_info
+	tcpInfoRTT          = 68
+	tcpInfoSndCwnd      = 80
+	tcpInfoTotalRetrans = 100
+	tcpInfoDeliveryRate = 160
+)
+
+var percentiles = []int{50, 90, 99}
+
+// tcpConn holds tcp_info values of single established connection
+type tcpConn struct {
+	local      net.IP
+	remote     net.IP
+	localPort  uint16
+	remotePort uint16
+	// smoothed round trip time in microseconds
+	rtt uint32
+	// total number of retransmitted segments
+	retrans uint32
+	// congestion window in segments
+	cwnd uint32
+	// delivery rate in bytes per second, zero when not reported by kernel
+	deliveryRate uint64
+}
+
+// tcpAggregation is grouping of connections of interface
+type tcpAggregation struct {
+	by      string
+	prefix4 int
+	prefix6 int
+}
+
+// tcpAggregationOf returns grouping set in config of interface
+func tcpAggregationOf(cfg config) (tcpAggregation, error) {
+	a := tcpAggregation{
+		by:      cfg.getString("tcp_aggregate", aggregateByPort),
+		prefix4: cfg.getInt("tcp_prefix_v4", 24),
+		prefix6: cfg.getInt("tcp_prefix_v6", 64),
+	}
+	if a.by != aggregateByPort && a.by != aggregateBySubnet {
+		return a, fmt.Errorf("Wrong tcp aggregation {%s}, expected {%s} or {%s}", a.by, aggregateByPort, aggregateBySubnet)
+	}
+	return a, nil
+}
+
+// getTCPStats dumps established tcp connections over NETLINK_SOCK_DIAG and
+// stores their aggregates in stats map under tcpKey, connections are grouped
+// as config of interface which has their local address says
+func getTCPStats(stats map[string]interface{}, cfg config, _ *sourceState) error {
+	def, err := tcpAggregationOf(cfg)
+	if err != nil {
+		return err
+	}
+	resolve, err := cfg.perInterface()
+	if err != nil {
+		return err
+	}
+	owners := addrInterfaces()
+	aggregations := map[string]tcpAggregation{}
+	for _, name := range owners {
+		if _, ok := aggregations[name]; ok {
+			continue
+		}
+		if aggregations[name], err = tcpAggregationOf(resolve(name)); err != nil {
+			return err
+		}
+	}
+
+	conns := []tcpConn{}
+	listening := map[uint16]bool{}
+	for _, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+		msgs, err := nlRequest(netlinkSockDiag, sockDiagByFamily, syscall.NLM_F_DUMP, inetDiagRequest(family, tcpEstablished, inetDiagInfo))
+		if err != nil {
+			return fmt.Errorf("Cannot dump tcp sockets: %v", err)
+		}
+		for _, m := range msgs {
+			conn, err := parseInetDiagMsg(m.Data)
+			if err != nil {
+				return err
+			}
+			if conn != nil {
+				conns = append(conns, *conn)
+			}
+		}
+
+		// listening sockets are dumped after connections, so that no
+		// connection is accepted on port which is not known as listening
+		msgs, err = nlRequest(netlinkSockDiag, sockDiagByFamily, syscall.NLM_F_DUMP, inetDiagRequest(family, tcpListen, 0))
+		if err != nil {
+			return fmt.Errorf("Cannot dump listening tcp sockets: %v", err)
+		}
+		for _, m := range msgs {
+			if len(m.Data) < sizeofInetDiagMsg {
+				return fmt.Errorf("Wrong inet_diag_msg length {%d}", len(m.Data))
+			}
+			listening[binary.BigEndian.Uint16(m.Data[4:6])] = true
+		}
+	}
+
+	stats[tcpKey] = aggregateTCPConns(conns, listening, func(c tcpConn) tcpAggregation {
+		if name, ok := owners[c.local.String()]; ok {
+			return aggregations[name]
+		}
+		return def
+	})
+	return nil
+}
+
+// addrInterfaces returns names of interfaces indexed by their addresses
+func addrInterfaces() map[string]string {
+	owners := map[string]string{}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return owners
+	}
+	for _, i := range ifaces {
+		addrs, err := i.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				owners[ipnet.IP.String()] = i.Name
+			}
+		}
+	}
+	return owners
+}
+
+// inetDiagRequest builds inet_diag_req_v2 asking for tcp sockets in given
+// state with given extension, e.g. tcp_info, zero extension asks for none
+func inetDiagRequest(family uint8, state uint, ext uint) []byte {
+	req := make([]byte, sizeofInetDiagReqV2)
+	req[0] = family
+	req[1] = syscall.IPPROTO_TCP
+	if ext > 0 {
+		req[2] = 1 << (ext - 1)
+	}
+	nativeEndian.PutUint32(req[4:8], 1<<state)
+	return req
+}
+
+// parseInetDiagMsg decodes inet_diag_msg with tcp_info attribute,
+// sockets without tcp_info are skipped
+func parseInetDiagMsg(b []byte) (*tcpConn, error) {
+	if len(b) < sizeofInetDiagMsg {
+		return nil, fmt.Errorf("Wrong inet_diag_msg length {%d}", len(b))
+	}
+
+	conn := &tcpConn{
+		localPort:  binary.BigEndian.Uint16(b[4:6]),
+		remotePort: binary.BigEndian.Uint16(b[6:8]),
+	}
+	switch b[0] {
+	case syscall.AF_INET:
+		conn.local = net.IP(append([]byte(nil), b[8:12]...))
+		conn.remote = net.IP(append([]byte(nil), b[24:28]...))
+	case syscall.AF_INET6:
+		conn.local = net.IP(append([]byte(nil), b[8:24]...))
+		conn.remote = net.IP(append([]byte(nil), b[24:40]...))
+	default:
+		return nil, fmt.Errorf("Wrong inet_diag_msg family {%d}", b[0])
+	}
+
+	attrs, err := attrMap(b[sizeofInetDiagMsg:])
+	if err != nil {
+		return nil, err
+	}
+	info, ok := attrs[inetDiagInfo]
+	if !ok || len(info) < tcpInfoTotalRetrans+4 {
+		return nil, nil
+	}
+
+	conn.rtt = nativeEndian.Uint32(info[tcpInfoRTT:])
+	conn.cwnd = nativeEndian.Uint32(info[tcpInfoSndCwnd:])
+	conn.retrans = nativeEndian.Uint32(info[tcpInfoTotalRetrans:])
+	if len(info) >= tcpInfoDeliveryRate+8 {
+		conn.deliveryRate = nativeEndian.Uint64(info[tcpInfoDeliveryRate:])
+	}
+	return conn, nil
+}
+
+// aggregateTCPConns groups connections by local port or remote subnet,
+// as aggregation of each connection says, and calculates percentiles of
+// their tcp_info values within each group; only listening ports have own
+// group, connections of other local ports are grouped under ephemeralKey
+func aggregateTCPConns(conns []tcpConn, listening map[uint16]bool, aggregation func(c tcpConn) tcpAggregation) map[string]interface{} {
+	groups := map[string]map[string][]tcpConn{}
+	for _, c := range conns {
+		a := aggregation(c)
+		key := ephemeralKey
+		switch {
+		case a.by == aggregateBySubnet:
+			key = subnetKey(c.remote, a.prefix4, a.prefix6)
+		case listening[c.localPort]:
+			key = strconv.Itoa(int(c.localPort))
+		}
+		if groups[a.by] == nil {
+			groups[a.by] = map[string][]tcpConn{}
+		}
+		groups[a.by][key] = append(groups[a.by][key], c)
+	}
+
+	result := map[string]interface{}{}
+	for by, byGroups := range groups {
+		result[by] = aggregateGroups(byGroups)
+	}
+	return result
+}
+
+// aggregateGroups calculates percentiles of tcp_info values within each group
+func aggregateGroups(groups map[string][]tcpConn) map[string]interface{} {
+	aggr := map[string]interface{}{}
+	for key, group := range groups {
+		rtt := make([]int64, len(group))
+		retrans := make([]int64, len(group))
+		cwnd := make([]int64, len(group))
+		rate := make([]int64, len(group))
+		for i, c := range group {
+			rtt[i] = int64(c.rtt)
+			retrans[i] = int64(c.retrans)
+			cwnd[i] = int64(c.cwnd)
+			rate[i] = int64(c.deliveryRate)
+		}
+
+		gstats := map[string]interface{}{"connections": int64(len(group))}
+		addPercentiles(gstats, "rtt", rtt)
+		addPercentiles(gstats, "retrans", retrans)
+		addPercentiles(gstats, "cwnd", cwnd)
+		addPercentiles(gstats, "delivery_rate", rate)
+		aggr[key] = gstats
+	}
+	return aggr
+}
+
+// subnetKey returns remote subnet in form usable as namespace element, e.g. 10.0.1.0_24
+func subnetKey(ip net.IP, prefix4, prefix6 int) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(prefix4, 32)).String() + "_" + strconv.Itoa(prefix4)
+	}
+	return ip.Mask(net.CIDRMask(prefix6, 128)).String() + "_" + strconv.Itoa(prefix6)
+}
+
+type int64Slice []int64
+
+func (s int64Slice) Len() int           { return len(s) }
+func (s int64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// addPercentiles stores nearest-rank percentiles of values as <name>_p<N>
+func addPercentiles(stats map[string]interface{}, name string, values []int64) {
+	sort.Sort(int64Slice(values))
+	for _, p := range percentiles {
+		stats[name+"_p"+strconv.Itoa(p)] = percentile(values, p)
+	}
+}
+
+// percentile returns nearest-rank percentile of sorted values
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,193 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestPercentile(t *testing.T) {
+	Convey("Given sorted values", t, func() {
+		values := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+		Convey("Nearest-rank percentiles are returned", func() {
+			So(percentile(values, 50), ShouldEqual, 5)
+			So(percentile(values, 90), ShouldEqual, 9)
+			So(percentile(values, 99), ShouldEqual, 10)
+			So(percentile([]int64{7}, 50), ShouldEqual, 7)
+			So(percentile([]int64{}, 50), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestParseInetDiagMsg(t *testing.T) {
+	Convey("Given inet_diag_msg with tcp_info attribute", t, func() {
+		info := make([]byte, tcpInfoDeliveryRate+8)
+		nativeEndian.PutUint32(info[tcpInfoRTT:], 1500)
+		nativeEndian.PutUint32(info[tcpInfoSndCwnd:], 10)
+		nativeEndian.PutUint32(info[tcpInfoTotalRetrans:], 3)
+		nativeEndian.PutUint64(info[tcpInfoDeliveryRate:], 125000)
+
+		msg := make([]byte, sizeofInetDiagMsg+nlaHdrLen+len(info))
+		msg[0] = syscall.AF_INET
+		msg[4], msg[5] = 0x1f, 0x90
+		copy(msg[8:12], []byte{10, 0, 0, 1})
+		copy(msg[24:28], []byte{10, 0, 1, 7})
+		nativeEndian.PutUint16(msg[sizeofInetDiagMsg:], uint16(nlaHdrLen+len(info)))
+		nativeEndian.PutUint16(msg[sizeofInetDiagMsg+2:], inetDiagInfo)
+		copy(msg[sizeofInetDiagMsg+nlaHdrLen:], info)
+
+		Convey("When message is parsed", func() {
+			conn, err := parseInetDiagMsg(msg)
+
+			Convey("Connection values are decoded", func() {
+				So(err, ShouldBeNil)
+				So(conn, ShouldNotBeNil)
+				So(conn.localPort, ShouldEqual, 8080)
+				So(conn.remote.String(), ShouldEqual, "10.0.1.7")
+				So(conn.rtt, ShouldEqual, 1500)
+				So(conn.cwnd, ShouldEqual, 10)
+				So(conn.retrans, ShouldEqual, 3)
+				So(conn.deliveryRate, ShouldEqual, 125000)
+			})
+		})
+
+		Convey("When message is truncated", func() {
+			_, err := parseInetDiagMsg(msg[:sizeofInetDiagMsg-1])
+
+			Convey("Error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestGetTCPStats(t *testing.T) {
+	Convey("Given tcp connection opened on loopback", t, func() {
+		ln, err := net.Listen("tcp4", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer ln.Close()
+
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			c, err := ln.Accept()
+			if err == nil {
+				io.Copy(c, c)
+				c.Close()
+			}
+			accepted <- c
+		}()
+
+		client, err := net.Dial("tcp4", ln.Addr().String())
+		So(err, ShouldBeNil)
+		defer client.Close()
+
+		buf := []byte("ping")
+		_, err = client.Write(buf)
+		So(err, ShouldBeNil)
+		_, err = io.ReadFull(client, buf)
+		So(err, ShouldBeNil)
+
+		port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+
+		Convey("When connections are aggregated by local port", func() {
+			stats := map[string]interface{}{}
+			err := getTCPStats(stats, config{}, nil)
+			So(err, ShouldBeNil)
+
+			Convey("Group of listening port holds accepted connection", func() {
+				byPort := stats[tcpKey].(map[string]interface{})[aggregateByPort].(map[string]interface{})
+				So(byPort, ShouldContainKey, port)
+				group := byPort[port].(map[string]interface{})
+				So(group["connections"], ShouldEqual, 1)
+				So(group["rtt_p50"].(int64), ShouldBeGreaterThan, 0)
+				So(group["cwnd_p99"].(int64), ShouldBeGreaterThan, 0)
+				So(group, ShouldContainKey, "retrans_p90")
+				So(group, ShouldContainKey, "delivery_rate_p50")
+			})
+
+			Convey("Outbound connection is folded into ephemeral group", func() {
+				byPort := stats[tcpKey].(map[string]interface{})[aggregateByPort].(map[string]interface{})
+				So(byPort, ShouldNotContainKey, strconv.Itoa(client.LocalAddr().(*net.TCPAddr).Port))
+				So(byPort, ShouldContainKey, ephemeralKey)
+				So(byPort[ephemeralKey].(map[string]interface{})["connections"].(int64), ShouldBeGreaterThanOrEqualTo, 1)
+			})
+		})
+
+		Convey("When connections are aggregated by remote subnet", func() {
+			stats := map[string]interface{}{}
+			cfg := config{"tcp_aggregate": ctypes.ConfigValueStr{Value: aggregateBySubnet}}
+			err := getTCPStats(stats, cfg, nil)
+			So(err, ShouldBeNil)
+
+			Convey("Loopback subnet holds both ends of connection", func() {
+				bySubnet := stats[tcpKey].(map[string]interface{})[aggregateBySubnet].(map[string]interface{})
+				So(bySubnet, ShouldContainKey, "127.0.0.0_24")
+				group := bySubnet["127.0.0.0_24"].(map[string]interface{})
+				So(group["connections"].(int64), ShouldBeGreaterThanOrEqualTo, 2)
+			})
+		})
+
+		Convey("When loopback has own aggregation", func() {
+			stats := map[string]interface{}{}
+			cfg := config{
+				"tcp_aggregate@^lo$": ctypes.ConfigValueStr{Value: aggregateBySubnet},
+				"tcp_prefix_v4@^lo$": ctypes.ConfigValueInt{Value: 8},
+			}
+			err := getTCPStats(stats, cfg, nil)
+			So(err, ShouldBeNil)
+
+			Convey("Its connections are grouped as override of loopback says", func() {
+				tcp := stats[tcpKey].(map[string]interface{})
+				So(tcp[aggregateBySubnet], ShouldContainKey, "127.0.0.0_8")
+				byPort, _ := tcp[aggregateByPort].(map[string]interface{})
+				So(byPort, ShouldNotContainKey, port)
+			})
+		})
+
+		Convey("When unknown aggregation is configured for interface", func() {
+			cfg := config{"tcp_aggregate@^lo$": ctypes.ConfigValueStr{Value: "process"}}
+			err := getTCPStats(map[string]interface{}{}, cfg, nil)
+
+			Convey("Error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When unknown aggregation is configured", func() {
+			cfg := config{"tcp_aggregate": ctypes.ConfigValueStr{Value: "process"}}
+			err := getTCPStats(map[string]interface{}{}, cfg, nil)
+
+			Convey("Error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
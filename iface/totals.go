@@ -0,0 +1,168 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// netnsRuns are directories of bind mounted network namespaces named
+// by ip netns and by docker
+var netnsRuns = []string{"/var/run/netns", "/var/run/docker/netns"}
+
+// totalStats are interface statistics summed per network namespace
+var totalStats = []string{"bytes_recv", "bytes_sent", "packets_recv", "packets_sent", "drop_recv", "drop_sent"}
+
+// netnsInfo is network namespace found on host
+type netnsInfo struct {
+	name string
+	// pid is process within namespace, 0 when namespace has only a name
+	pid  int
+	path string
+}
+
+// nsInode returns inode of namespace file, which identifies namespace
+func nsInode(path string) (uint64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, syscall.EINVAL
+	}
+	return st.Ino, nil
+}
+
+// findNetns returns network namespaces of processes, of namespace names and
+// of configured namespaces, indexed by inode
+func findNetns(targets []netnsTarget) map[uint64]*netnsInfo {
+	found := map[uint64]*netnsInfo{}
+
+	dirs, _ := ioutil.ReadDir(procPath)
+	pids := []int{}
+	for _, d := range dirs {
+		if pid, err := strconv.Atoi(d.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	for _, pid := range pids {
+		path := filepath.Join(procPath, strconv.Itoa(pid), "ns", "net")
+		ino, err := nsInode(path)
+		if err != nil {
+			// process exited or is not accessible
+			continue
+		}
+		if _, ok := found[ino]; !ok {
+			found[ino] = &netnsInfo{pid: pid, path: path}
+		}
+	}
+
+	name := func(name, path string) {
+		ino, err := nsInode(path)
+		if err != nil {
+			return
+		}
+		if n, ok := found[ino]; ok {
+			if n.name == "" {
+				n.name = name
+			}
+			return
+		}
+		found[ino] = &netnsInfo{name: name, path: path}
+	}
+	for _, t := range targets {
+		name(t.name, t.path)
+	}
+	for _, dir := range netnsRuns {
+		files, _ := ioutil.ReadDir(dir)
+		for _, f := range files {
+			name(f.Name(), filepath.Join(dir, f.Name()))
+		}
+	}
+	return found
+}
+
+// getNetnsTotals returns traffic of interfaces other than loopback summed
+// per network namespace, namespaces are indexed by inode and tagged by name
+// when it is known; namespaces beyond max_elements are summed into overflow
+func (iface *ifacePlugin) getNetnsTotals(cfg config) map[string]interface{} {
+	targets, err := parseNetns(cfg.getString("netns", ""))
+	if err != nil {
+		log.Warn("Cannot parse network namespaces, ", err)
+	}
+	_, canEnter := iface.disabled[netnsKey]
+	canEnter = !canEnter
+
+	totals := map[string]interface{}{}
+	for ino, n := range findNetns(targets) {
+		var stats map[string]interface{}
+		switch {
+		case n.pid > 0:
+			// /proc/<pid>/net shows network namespace of the process
+			stats = map[string]interface{}{}
+			err = readNetDev(filepath.Join(procPath, strconv.Itoa(n.pid), "net", "dev"), stats)
+		case canEnter:
+			stats, err = netnsTarget{name: n.name, path: n.path}.stats(false)
+		default:
+			continue
+		}
+		if err != nil {
+			log.WithFields(log.Fields{"netns": ino, "name": n.name}).Warn("Cannot read network namespace, ", err)
+			continue
+		}
+
+		var tags map[string]string
+		if n.name != "" {
+			tags = map[string]string{"netns": n.name}
+		}
+		nstotals := map[string]interface{}{}
+		for _, stat := range totalStats {
+			sum := int64(0)
+			for name, istats := range stats {
+				if name == "lo" {
+					continue
+				}
+				if v, ok := istats.(map[string]interface{})[stat].(int64); ok && v > 0 {
+					sum += v
+				}
+			}
+			nstotals[stat] = taggedValue{value: sum, tags: tags}
+		}
+		totals[strconv.FormatUint(ino, 10)] = nstotals
+	}
+
+	if max := cfg.getInt("max_elements", 0); max > 0 {
+		if folded := foldLevel(totals, max); folded > 0 {
+			log.WithFields(log.Fields{"folded": folded, "max_elements": max}).Warn("Number of network namespaces exceeds limit, folding them into ", overflowKey)
+		}
+	}
+	return totals
+}
@@ -0,0 +1,146 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestNetnsTotals(t *testing.T) {
+	Convey("Given procfs fixtures with processes in two network namespaces", t, func() {
+		procPath = "../examples/test/proc"
+		netnsRuns = []string{"../examples/test/run/netns", "../examples/test/run/docker/netns"}
+		ifaceInfo = "../examples/test/proc/1/net/dev"
+		defer func() {
+			procPath = "/proc"
+			netnsRuns = []string{"/var/run/netns", "/var/run/docker/netns"}
+			ifaceInfo = "/proc/net/dev"
+		}()
+
+		hostIno, err := nsInode("../examples/test/proc/1/ns/net")
+		So(err, ShouldBeNil)
+		webIno, err := nsInode("../examples/test/proc/4242/ns/net")
+		So(err, ShouldBeNil)
+		host, web := strconv.FormatUint(hostIno, 10), strconv.FormatUint(webIno, 10)
+
+		ifacePlg := &ifacePlugin{stats: map[string]interface{}{}, host: "host", disabled: map[string][]string{netnsKey: {"CAP_SYS_ADMIN"}}}
+
+		Convey("When totals are read", func() {
+			totals := ifacePlg.getNetnsTotals(config{})
+
+			Convey("Each namespace is listed once by its inode", func() {
+				So(totals, ShouldHaveLength, 2)
+				So(totals, ShouldContainKey, host)
+				So(totals, ShouldContainKey, web)
+			})
+
+			Convey("Traffic of interfaces other than loopback is summed", func() {
+				So(totals[host], ShouldResemble, map[string]interface{}{
+					"bytes_recv":   taggedValue{value: int64(1417260323)},
+					"bytes_sent":   taggedValue{value: int64(6524444724)},
+					"packets_recv": taggedValue{value: int64(12278796)},
+					"packets_sent": taggedValue{value: int64(17096750)},
+					"drop_recv":    taggedValue{value: int64(12)},
+					"drop_sent":    taggedValue{value: int64(5)},
+				})
+			})
+
+			Convey("Namespace is tagged by its name", func() {
+				So(totals[web].(map[string]interface{})["bytes_recv"], ShouldResemble,
+					taggedValue{value: int64(90211331), tags: map[string]string{"netns": "web"}})
+			})
+		})
+
+		Convey("When namespace is named in config", func() {
+			cfg := config{"netns": ctypes.ConfigValueStr{Value: "init=../examples/test/proc/1/ns/net"}}
+			totals := ifacePlg.getNetnsTotals(cfg)
+
+			Convey("The name is used as tag", func() {
+				So(totals[host].(map[string]interface{})["drop_sent"], ShouldResemble,
+					taggedValue{value: int64(5), tags: map[string]string{"netns": "init"}})
+			})
+		})
+
+		Convey("When namespaces exceed max_elements", func() {
+			node := cdata.NewNode()
+			node.AddItem("netns_totals", ctypes.ConfigValueBool{Value: true})
+			node.AddItem("max_elements", ctypes.ConfigValueInt{Value: 1})
+			totals := ifacePlg.getNetnsTotals(newConfig(node))
+			first, folded := host, int64(4412503)
+			if web < host {
+				first, folded = web, int64(6524444724)
+			}
+
+			Convey("Namespaces beyond the first one in name order are summed into overflow", func() {
+				So(totals, ShouldHaveLength, 2)
+				So(totals, ShouldContainKey, first)
+				So(totals[overflowKey].(map[string]interface{})["bytes_sent"], ShouldEqual, folded)
+			})
+
+			Convey("Overflow is collected without tags", func() {
+				mTypes := []plugin.PluginMetricType{{Namespace_: strings.Split("intel/procfs/netns/_overflow/bytes_sent", "/"), Config_: node}}
+				metrics, err := ifacePlg.CollectMetrics(mTypes)
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Data_, ShouldEqual, folded)
+				So(metrics[0].Tags_, ShouldBeNil)
+			})
+		})
+
+		Convey("When totals are enabled", func() {
+			node := cdata.NewNode()
+			node.AddItem("netns_totals", ctypes.ConfigValueBool{Value: true})
+
+			Convey("They are listed in catalog", func() {
+				mts, err := ifacePlg.GetMetricTypes(plugin.PluginConfigType{ConfigDataNode: node})
+				So(err, ShouldBeNil)
+				namespaces := []string{}
+				for _, m := range mts {
+					namespaces = append(namespaces, strings.Join(m.Namespace(), "/"))
+				}
+				So(namespaces, ShouldContain, "intel/procfs/netns/"+web+"/packets_sent")
+				So(namespaces, ShouldContain, "intel/procfs/iface/eth0/bytes_recv")
+			})
+
+			Convey("They are collected next to interface metrics", func() {
+				mTypes := []plugin.PluginMetricType{}
+				for _, ns := range []string{"netns/" + web + "/bytes_sent", "netns/1/bytes_sent", "iface/eth0/bytes_sent"} {
+					mTypes = append(mTypes, plugin.PluginMetricType{Namespace_: strings.Split("intel/procfs/"+ns, "/"), Config_: node})
+				}
+				metrics, err := ifacePlg.CollectMetrics(mTypes)
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 2)
+				So(metrics[0].Data_, ShouldEqual, 4412503)
+				So(metrics[0].Tags_, ShouldResemble, map[string]string{"netns": "web"})
+				So(metrics[1].Data_, ShouldEqual, 6434234393)
+			})
+		})
+	})
+}
@@ -0,0 +1,101 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+)
+
+const (
+	// IFLA_INFO_DATA attributes of tunnels, see linux/if_link.h and linux/if_tunnel.h
+	iflaVxlanID     = 1
+	iflaVxlanGroup  = 2
+	iflaVxlanLocal  = 4
+	iflaVxlanGroup6 = 16
+	iflaVxlanLocal6 = 17
+
+	iflaGeneveID      = 1
+	iflaGeneveRemote  = 2
+	iflaGeneveRemote6 = 7
+
+	iflaGreIflags = 2
+	iflaGreOflags = 3
+	iflaGreIkey   = 4
+	iflaGreOkey   = 5
+	iflaGreLocal  = 6
+	iflaGreRemote = 7
+
+	iflaIptunLocal  = 2
+	iflaIptunRemote = 3
+
+	// greKey is GRE_KEY flag of big endian GRE flags
+	greKey = 0x2000
+)
+
+// tunnelTags describes tunnel interface by its kind, VNI or keys
+// and endpoints, other interfaces are not tagged
+func tunnelTags(l link) map[string]string {
+	tags := map[string]string{}
+	addIP := func(name string, b ...[]byte) {
+		for _, ip := range b {
+			if len(ip) == net.IPv4len || len(ip) == net.IPv6len {
+				if ip := net.IP(ip); !ip.IsUnspecified() {
+					tags[name] = ip.String()
+					return
+				}
+			}
+		}
+	}
+
+	switch l.kind {
+	case "vxlan":
+		if b := l.data[iflaVxlanID]; len(b) == 4 {
+			tags["vni"] = strconv.FormatUint(uint64(nativeEndian.Uint32(b)), 10)
+		}
+		addIP("remote", l.data[iflaVxlanGroup], l.data[iflaVxlanGroup6])
+		addIP("local", l.data[iflaVxlanLocal], l.data[iflaVxlanLocal6])
+	case "geneve":
+		if b := l.data[iflaGeneveID]; len(b) == 4 {
+			tags["vni"] = strconv.FormatUint(uint64(nativeEndian.Uint32(b)), 10)
+		}
+		addIP("remote", l.data[iflaGeneveRemote], l.data[iflaGeneveRemote6])
+	case "gre", "gretap", "ip6gre", "ip6gretap":
+		greKeyTag := func(name string, flags, key []byte) {
+			if len(flags) == 2 && len(key) == 4 && binary.BigEndian.Uint16(flags)&greKey != 0 {
+				tags[name] = strconv.FormatUint(uint64(binary.BigEndian.Uint32(key)), 10)
+			}
+		}
+		greKeyTag("ikey", l.data[iflaGreIflags], l.data[iflaGreIkey])
+		greKeyTag("okey", l.data[iflaGreOflags], l.data[iflaGreOkey])
+		addIP("remote", l.data[iflaGreRemote])
+		addIP("local", l.data[iflaGreLocal])
+	case "ipip", "sit", "ip6tnl":
+		addIP("remote", l.data[iflaIptunRemote])
+		addIP("local", l.data[iflaIptunLocal])
+	default:
+		return nil
+	}
+	tags["kind"] = l.kind
+	return tags
+}
@@ -0,0 +1,73 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTunnelTags(t *testing.T) {
+	Convey("Given recorded link dump with tunnel interfaces", t, func() {
+		links, err := readLinks("../examples/test/rtnl.links")
+		So(err, ShouldBeNil)
+		tags := map[string]map[string]string{}
+		for _, l := range links {
+			tags[l.name] = tunnelTags(l)
+		}
+
+		Convey("VXLAN interfaces are described by VNI and endpoints", func() {
+			So(tags["vxlan100"], ShouldResemble, map[string]string{
+				"kind": "vxlan", "vni": "100", "remote": "10.0.0.2", "local": "10.0.0.1",
+			})
+			So(tags["vxlan6"], ShouldResemble, map[string]string{
+				"kind": "vxlan", "vni": "4096", "remote": "fd00::2", "local": "fd00::1",
+			})
+		})
+
+		Convey("GRE interfaces are described by keys and endpoints", func() {
+			So(tags["gre1"], ShouldResemble, map[string]string{
+				"kind": "gre", "ikey": "42", "okey": "42", "remote": "10.0.0.3", "local": "10.0.0.1",
+			})
+		})
+
+		Convey("IP in IP interfaces are described by endpoints", func() {
+			So(tags["ipip1"], ShouldResemble, map[string]string{
+				"kind": "ipip", "remote": "10.0.0.4", "local": "10.0.0.1",
+			})
+			So(tags["sit1"], ShouldResemble, map[string]string{
+				"kind": "sit", "remote": "10.0.0.5",
+			})
+		})
+
+		Convey("Geneve interfaces are described by VNI and remote endpoint", func() {
+			So(tags["gnv0"], ShouldResemble, map[string]string{
+				"kind": "geneve", "vni": "200", "remote": "10.0.0.6",
+			})
+		})
+
+		Convey("Other interfaces are not tagged", func() {
+			So(tags["lo"], ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,251 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+// Report describes what the plugin produces with given config on this host
+type Report struct {
+	// Errors are reasons for snap or the plugin to reject the config
+	Errors []error
+	// Warnings describe config which is accepted but does not work as expected
+	Warnings []string
+	// Interfaces are names of interfaces of the host, procfs roots and network namespaces
+	Interfaces []string
+	// Sources are optional sources of metrics and interface tags
+	Sources []SourceStatus
+	// Metrics are namespaces of metrics in the catalog
+	Metrics []string
+}
+
+// SourceStatus tells whether optional source is enabled and can be read
+type SourceStatus struct {
+	// Name is namespace element of source metrics or config item enabling tags
+	Name    string
+	Enabled bool
+	// Err is set when enabled source cannot be read
+	Err error
+}
+
+// Validate checks config against config policy of the plugin and resolves
+// interfaces, sources and metrics which the plugin produces with it, sources
+// are read once without state, so no sampler is left running and no baseline is kept
+func Validate(node *cdata.ConfigDataNode) (*Report, error) {
+	report := &Report{}
+	iface := &ifacePlugin{stats: map[string]interface{}{}}
+	cfg := newConfig(node)
+	// explicit items without those of preset
+	explicit := config{}
+	if node != nil {
+		explicit = config(node.Table())
+	}
+	if _, err := explicit.withPreset(); err != nil {
+		report.Errors = append(report.Errors, err)
+	}
+	if caps, err := effectiveCaps(procStatus); err == nil {
+		iface.disabled = disabledSources(caps)
+	}
+
+	policy, err := iface.GetConfigPolicy()
+	if err != nil {
+		return nil, err
+	}
+	rules := policy.Get([]string{VENDOR, FS, PLUGIN})
+	known := map[string]bool{}
+	for _, r := range rules.RulesAsTable() {
+		known[r.Name] = true
+	}
+	keys := []string{}
+	for k := range cfg {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := map[string]ctypes.ConfigValue{}
+	for _, k := range keys {
+		item := strings.SplitN(k, overrideSep, 2)[0]
+		if !known[item] {
+			report.warn("Unknown config item {%s} is ignored", k)
+			continue
+		}
+		if item == k {
+			items[k] = cfg[k]
+			continue
+		}
+		if _, _, err := parseOverride(k); err != nil {
+			report.Errors = append(report.Errors, err)
+			continue
+		}
+		if _, errs := rules.Process(map[string]ctypes.ConfigValue{item: cfg[k]}); errs.HasErrors() {
+			report.Errors = append(report.Errors, errs.Errors()...)
+		}
+	}
+	if _, errs := rules.Process(items); errs.HasErrors() {
+		report.Errors = append(report.Errors, errs.Errors()...)
+	}
+	roots, namespaces, err := parseNamedConfig(cfg)
+	if err != nil {
+		report.Errors = append(report.Errors, err)
+	}
+	backend := cfg.getString("stats_backend", statsBackendProcfs)
+	if err := checkStatsBackend(backend); err != nil {
+		report.Errors = append(report.Errors, err)
+	}
+	if len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	if err := getStats(iface.stats, backend); err != nil {
+		return nil, err
+	}
+	hostIfaces := []string{}
+	for name := range iface.stats {
+		if !strings.HasPrefix(name, "_") {
+			hostIfaces = append(hostIfaces, name)
+		}
+	}
+	names := map[string]bool{}
+	for _, name := range hostIfaces {
+		names[name] = true
+	}
+	for _, r := range roots {
+		rstats, err := r.stats()
+		if err != nil {
+			report.warn("Cannot read procfs root {%s}, %v", r.name, err)
+			continue
+		}
+		for name := range rstats {
+			names[name] = true
+		}
+	}
+	if missing, ok := iface.disabled[netnsKey]; ok && len(namespaces) > 0 {
+		report.warn("Network namespaces are not entered, run the plugin with %s", strings.Join(missing, " and "))
+		namespaces = nil
+	}
+	for _, n := range namespaces {
+		nstats, err := n.stats(false)
+		if err != nil {
+			report.warn("Cannot read network namespace {%s}, %v", n.name, err)
+			continue
+		}
+		for name := range nstats {
+			names[name] = true
+		}
+	}
+	for name := range names {
+		report.Interfaces = append(report.Interfaces, name)
+	}
+	sort.Strings(report.Interfaces)
+	if max := cfg.getInt("max_interfaces", 0); max > 0 && len(hostIfaces) > max {
+		report.warn("%d interfaces exceed max_interfaces {%d}, the rest is folded into %s", len(hostIfaces), max, overflowKey)
+	}
+
+	for _, s := range sources {
+		status := SourceStatus{Name: s.key, Enabled: s.enabled(cfg)}
+		if !status.Enabled {
+			for _, item := range s.options {
+				for _, k := range keys {
+					if _, ok := explicit[k]; ok && strings.SplitN(k, overrideSep, 2)[0] == item {
+						report.warn("Config item {%s} has no effect unless one of %v is enabled", k, s.enable)
+					}
+				}
+			}
+			report.Sources = append(report.Sources, status)
+			continue
+		}
+
+		if missing, ok := iface.disabled[s.key]; ok {
+			status.Err = fmt.Errorf("Missing capabilities %s", strings.Join(missing, ", "))
+			report.warn("Source {%s} lacks privileges, run the plugin with %s", s.key, strings.Join(missing, " and "))
+			report.Sources = append(report.Sources, status)
+			continue
+		}
+
+		// source is read into separate map to tell whether it adds any metrics
+		stats := map[string]interface{}{}
+		for _, name := range hostIfaces {
+			stats[name] = map[string]interface{}{}
+		}
+		if status.Err = s.collect(stats, cfg, nil); status.Err != nil {
+			report.warnUnavailable(s.key, status.Err)
+		} else if !hasMetrics(stats) {
+			report.warn("Source {%s} is enabled but has no metrics on this host", s.key)
+		}
+		report.Sources = append(report.Sources, status)
+	}
+	for _, s := range tagSources {
+		status := SourceStatus{Name: s.enable, Enabled: cfg.getBool(s.enable, false)}
+		for k, v := range cfg {
+			if strings.HasPrefix(k, s.enable+overrideSep) && v == (ctypes.ConfigValueBool{Value: true}) {
+				status.Enabled = true
+			}
+		}
+		if status.Enabled {
+			if _, status.Err = getLinks(); status.Err != nil {
+				report.warnUnavailable(s.enable, status.Err)
+			}
+		}
+		report.Sources = append(report.Sources, status)
+	}
+
+	mts, err := iface.GetMetricTypes(plugin.PluginConfigType{ConfigDataNode: node})
+	if err != nil {
+		return nil, err
+	}
+	for _, mt := range mts {
+		report.Metrics = append(report.Metrics, "/"+strings.Join(mt.Namespace(), "/"))
+	}
+	sort.Strings(report.Metrics)
+	return report, nil
+}
+
+// hasMetrics tells whether nested stats map has any value
+func hasMetrics(stats map[string]interface{}) bool {
+	for _, v := range stats {
+		if m, ok := v.(map[string]interface{}); !ok || hasMetrics(m) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// warnUnavailable tells missing privileges apart from other failures
+// as they are fixed by running the plugin with more capabilities
+func (r *Report) warnUnavailable(name string, err error) {
+	if os.IsPermission(err) {
+		r.warn("Source {%s} lacks privileges, run the plugin as root or with CAP_NET_ADMIN and CAP_NET_RAW, %v", name, err)
+		return
+	}
+	r.warn("Source {%s} is unavailable, %v", name, err)
+}
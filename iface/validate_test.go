@@ -0,0 +1,133 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("Given host with procfs fixtures", t, func() {
+		ifaceInfo = "../examples/test/proc.net.dev"
+		conntrackInfo = "../examples/test/proc.net.nf_conntrack"
+		defer func() { ifaceInfo, conntrackInfo = "/proc/net/dev", "/proc/net/nf_conntrack" }()
+		node := cdata.NewNode()
+
+		Convey("When valid config is checked", func() {
+			node.AddItem("proc_roots", ctypes.ConfigValueStr{Value: "vm1=../examples/test/roots/vm1,gone=../examples/test/roots/gone"})
+			node.AddItem("conntrack_entries", ctypes.ConfigValueBool{Value: true})
+			node.AddItem("max_interfaces", ctypes.ConfigValueInt{Value: 1})
+			node.AddItem("packet_sample_rate", ctypes.ConfigValueInt{Value: 10})
+			node.AddItem("tcp_prefix_v4@^stor", ctypes.ConfigValueInt{Value: 28})
+			node.AddItem("tcp_infos", ctypes.ConfigValueBool{Value: true})
+			report, err := Validate(node)
+			So(err, ShouldBeNil)
+
+			Convey("Interfaces of host and procfs roots are resolved", func() {
+				So(report.Errors, ShouldBeEmpty)
+				So(report.Interfaces, ShouldResemble, []string{"eth0", "lo", "p3p1"})
+			})
+
+			Convey("Enabled sources are read", func() {
+				So(report.Sources, ShouldContain, SourceStatus{Name: conntrackKey, Enabled: true})
+				So(report.Sources, ShouldContain, SourceStatus{Name: tcpKey})
+			})
+
+			Convey("Catalog metrics are listed", func() {
+				So(report.Metrics, ShouldContain, "/intel/procfs/iface/eth0/bytes_recv")
+				So(report.Metrics, ShouldContain, "/intel/procfs/iface/_conntrack/entries/total")
+				So(report.Metrics, ShouldContain, "/intel/procfs/iface/_overflow/interfaces")
+			})
+
+			Convey("Config which does not work as expected is reported", func() {
+				So(report.Warnings, ShouldResemble, []string{
+					"Unknown config item {tcp_infos} is ignored",
+					"Cannot read procfs root {gone}, open ../examples/test/roots/gone/net/dev: no such file or directory",
+					"2 interfaces exceed max_interfaces {1}, the rest is folded into _overflow",
+					"Config item {tcp_prefix_v4@^stor} has no effect unless one of [tcp_info] is enabled",
+					"Config item {packet_sample_rate} has no effect unless one of [packet_sample] is enabled",
+				})
+			})
+		})
+
+		Convey("When enabled source cannot be read", func() {
+			conntrackInfo = "../examples/test/proc.net.nf_conntrack.gone"
+			node.AddItem("conntrack_top", ctypes.ConfigValueBool{Value: true})
+			report, err := Validate(node)
+			So(err, ShouldBeNil)
+
+			Convey("Source is reported unavailable", func() {
+				for _, s := range report.Sources {
+					if s.Name == conntrackKey {
+						So(s.Err, ShouldNotBeNil)
+					}
+				}
+				So(report.Warnings, ShouldHaveLength, 1)
+				So(report.Warnings[0], ShouldStartWith, "Source {_conntrack} is unavailable")
+			})
+		})
+
+		Convey("When config items have wrong type or value", func() {
+			node.AddItem("tcp_info", ctypes.ConfigValueStr{Value: "yes"})
+			node.AddItem("max_elements", ctypes.ConfigValueInt{Value: -1})
+			node.AddItem("proc_roots", ctypes.ConfigValueStr{Value: "/mnt/vm1/proc"})
+			report, err := Validate(node)
+			So(err, ShouldBeNil)
+
+			Convey("Errors are reported", func() {
+				So(report.Errors, ShouldHaveLength, 3)
+				So(report.Interfaces, ShouldBeEmpty)
+				So(report.Metrics, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When preset is unknown", func() {
+			node.AddItem("preset", ctypes.ConfigValueStr{Value: "mainframe"})
+			report, err := Validate(node)
+			So(err, ShouldBeNil)
+
+			Convey("Error is reported", func() {
+				So(report.Errors, ShouldHaveLength, 1)
+				So(report.Errors[0].Error(), ShouldStartWith, "Unknown preset {mainframe}")
+			})
+		})
+
+		Convey("When overrides of interfaces are wrong", func() {
+			node.AddItem("vrf@(eth", ctypes.ConfigValueBool{Value: true})
+			node.AddItem("max_elements@eth", ctypes.ConfigValueInt{Value: 1})
+			node.AddItem("tunnel_tags@^vxlan", ctypes.ConfigValueStr{Value: "yes"})
+			node.AddItem("tunnels@^vxlan", ctypes.ConfigValueBool{Value: true})
+			report, err := Validate(node)
+			So(err, ShouldBeNil)
+
+			Convey("Errors are reported", func() {
+				So(report.Errors, ShouldHaveLength, 3)
+				So(report.Warnings, ShouldResemble, []string{"Unknown config item {tunnels@^vxlan} is ignored"})
+			})
+		})
+	})
+}
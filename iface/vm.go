@@ -0,0 +1,210 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// libvirtRun is directory of libvirt runtime domain XMLs
+	libvirtRun = "/run/libvirt/qemu"
+	procPath   = "/proc"
+)
+
+// vm identifies virtual machine which owns interface
+type vm struct {
+	name string
+	uuid string
+}
+
+// libvirtDomain holds fields of libvirt domain XML needed to map interfaces
+type libvirtDomain struct {
+	Name    string `xml:"name"`
+	UUID    string `xml:"uuid"`
+	Targets []struct {
+		Dev string `xml:"dev,attr"`
+	} `xml:"devices>interface>target"`
+}
+
+// libvirtStatus is runtime domain XML which wraps domain definition
+type libvirtStatus struct {
+	XMLName xml.Name
+	Domain  libvirtDomain `xml:"domain"`
+}
+
+// vmTags describes interfaces of virtual machines by VM name and UUID,
+// runtime domain XMLs of libvirt take precedence over QEMU processes
+func vmTags(links []link) map[string]map[string]string {
+	vms := qemuInterfaces(procPath)
+	for name, v := range libvirtInterfaces(libvirtRun) {
+		vms[name] = v
+	}
+
+	tags := map[string]map[string]string{}
+	for _, l := range links {
+		v, ok := vms[l.name]
+		if !ok {
+			continue
+		}
+		tags[l.name] = map[string]string{"vm_name": v.name}
+		if v.uuid != "" {
+			tags[l.name]["vm_uuid"] = v.uuid
+		}
+	}
+	return tags
+}
+
+// libvirtInterfaces returns VMs indexed by names of their interfaces
+// based on runtime domain XMLs, invalid files are skipped
+func libvirtInterfaces(dir string) map[string]vm {
+	vms := map[string]vm{}
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		return vms
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		status := libvirtStatus{}
+		if err := xml.Unmarshal(b, &status); err != nil {
+			continue
+		}
+		domain := status.Domain
+		if status.XMLName.Local == "domain" {
+			if err := xml.Unmarshal(b, &domain); err != nil {
+				continue
+			}
+		}
+		if domain.Name == "" {
+			continue
+		}
+		for _, t := range domain.Targets {
+			if t.Dev != "" {
+				vms[t.Dev] = vm{name: domain.Name, uuid: domain.UUID}
+			}
+		}
+	}
+	return vms
+}
+
+// qemuInterfaces returns VMs indexed by names of their interfaces based on
+// QEMU processes, interfaces are found in ifname options of command line and
+// in tun devices opened by the process, which covers taps passed by file descriptor
+func qemuInterfaces(proc string) map[string]vm {
+	vms := map[string]vm{}
+	dirs, err := ioutil.ReadDir(proc)
+	if err != nil {
+		return vms
+	}
+	for _, d := range dirs {
+		if _, err := strconv.Atoi(d.Name()); err != nil || !d.IsDir() {
+			continue
+		}
+		cmdline, err := ioutil.ReadFile(filepath.Join(proc, d.Name(), "cmdline"))
+		if err != nil {
+			continue
+		}
+		args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+		if !strings.HasPrefix(filepath.Base(args[0]), "qemu") {
+			continue
+		}
+
+		v, ifnames := parseQemuArgs(args)
+		if v.name == "" {
+			continue
+		}
+		ifnames = append(ifnames, tunInterfaces(filepath.Join(proc, d.Name(), "fdinfo"))...)
+		for _, name := range ifnames {
+			vms[name] = v
+		}
+	}
+	return vms
+}
+
+// parseQemuArgs returns VM name and UUID and interfaces named
+// in ifname options, e.g. -netdev tap,id=net0,ifname=vnet12
+func parseQemuArgs(args []string) (vm, []string) {
+	v := vm{}
+	ifnames := []string{}
+	for i, arg := range args {
+		next := ""
+		if i+1 < len(args) {
+			next = args[i+1]
+		}
+		switch arg {
+		case "-name":
+			// -name guest=vm1,debug-threads=on or -name vm1
+			for _, opt := range strings.Split(next, ",") {
+				if strings.HasPrefix(opt, "guest=") {
+					v.name = strings.TrimPrefix(opt, "guest=")
+					break
+				}
+				if !strings.Contains(opt, "=") && v.name == "" {
+					v.name = opt
+				}
+			}
+		case "-uuid":
+			v.uuid = next
+		}
+		for _, opt := range strings.Split(arg, ",") {
+			if strings.HasPrefix(opt, "ifname=") {
+				ifnames = append(ifnames, strings.TrimPrefix(opt, "ifname="))
+			}
+		}
+	}
+	return v, ifnames
+}
+
+// tunInterfaces returns names of tun and tap interfaces attached to
+// file descriptors of process, fdinfo of such descriptors has iff line
+func tunInterfaces(fdinfo string) []string {
+	names := []string{}
+	files, err := ioutil.ReadDir(fdinfo)
+	if err != nil {
+		return names
+	}
+	for _, f := range files {
+		fh, err := os.Open(filepath.Join(fdinfo, f.Name()))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(fh)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if bytes.HasPrefix(line, []byte("iff:")) {
+				names = append(names, string(bytes.TrimSpace(line[4:])))
+			}
+		}
+		fh.Close()
+	}
+	return names
+}
@@ -0,0 +1,102 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const web01UUID = "2f0a5b9e-7c3d-4e8a-9b1f-6d2c4a8e0f13"
+
+func TestLibvirtInterfaces(t *testing.T) {
+	Convey("Given directory of libvirt runtime domain XMLs", t, func() {
+		Convey("When interfaces are mapped to domains", func() {
+			vms := libvirtInterfaces("../examples/test/run/libvirt/qemu")
+
+			Convey("Interface targets of valid domains are returned", func() {
+				So(vms, ShouldResemble, map[string]vm{
+					"vnet12": {name: "web01", uuid: web01UUID},
+					"vnet13": {name: "web01", uuid: web01UUID},
+				})
+			})
+		})
+	})
+}
+
+func TestQemuInterfaces(t *testing.T) {
+	Convey("Given procfs with QEMU processes", t, func() {
+		Convey("When interfaces are mapped to processes", func() {
+			vms := qemuInterfaces("../examples/test/proc")
+
+			Convey("Taps passed by file descriptor and named taps are returned", func() {
+				So(vms, ShouldResemble, map[string]vm{
+					"vnet12": {name: "web01", uuid: web01UUID},
+					"vnet13": {name: "web01", uuid: web01UUID},
+					"tap0":   {name: "build-vm"},
+				})
+			})
+		})
+	})
+
+	Convey("Given QEMU command line with plain VM name", t, func() {
+		v, ifnames := parseQemuArgs([]string{"qemu-kvm", "-name", "vm1", "-net", "tap,ifname=tap3"})
+
+		Convey("Name and interfaces are returned", func() {
+			So(v.name, ShouldEqual, "vm1")
+			So(ifnames, ShouldResemble, []string{"tap3"})
+		})
+	})
+}
+
+func TestVMTags(t *testing.T) {
+	Convey("Given libvirt and procfs fixtures", t, func() {
+		libvirtRun = "../examples/test/run/libvirt/qemu"
+		procPath = "../examples/test/proc"
+		defer func() {
+			libvirtRun = "/run/libvirt/qemu"
+			procPath = "/proc"
+		}()
+		links := []link{{name: "eth0"}, {name: "vnet12", kind: "tun"}, {name: "tap0", kind: "tun"}}
+
+		Convey("When VM tags are read", func() {
+			tags := vmTags(links)
+
+			Convey("Interfaces of VMs are tagged with VM name and UUID", func() {
+				So(tags, ShouldResemble, map[string]map[string]string{
+					"vnet12": {"vm_name": "web01", "vm_uuid": web01UUID},
+					"tap0":   {"vm_name": "build-vm"},
+				})
+			})
+		})
+
+		Convey("When libvirt is not running", func() {
+			libvirtRun = "../examples/test/run/libvirt/none"
+			tags := vmTags(links)
+
+			Convey("Interfaces are mapped by QEMU processes", func() {
+				So(tags["vnet12"], ShouldResemble, map[string]string{"vm_name": "web01", "vm_uuid": web01UUID})
+			})
+		})
+	})
+}
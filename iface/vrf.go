@@ -0,0 +1,131 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// vrfKey is namespace element under which per VRF traffic is published
+	vrfKey = "_vrf"
+
+	// iflaVrfTable is routing table of VRF device, see linux/if_link.h
+	iflaVrfTable = 1
+)
+
+// vrfDevice is VRF master device with its routing table, table is empty
+// when VRF is detected by sysfs only
+type vrfDevice struct {
+	name  string
+	table string
+}
+
+// vrfMasters returns VRF devices indexed by interface index
+func vrfMasters(links []link) map[int]vrfDevice {
+	vrfs := map[int]vrfDevice{}
+	for _, l := range links {
+		switch {
+		case l.kind == "vrf":
+			v := vrfDevice{name: l.name}
+			if b := l.data[iflaVrfTable]; len(b) == 4 {
+				v.table = strconv.FormatUint(uint64(nativeEndian.Uint32(b)), 10)
+			}
+			vrfs[l.index] = v
+		case l.kind == "" && isSysfsVRF(l.name):
+			vrfs[l.index] = vrfDevice{name: l.name}
+		}
+	}
+	return vrfs
+}
+
+// isSysfsVRF checks device type in uevent attribute of interface
+func isSysfsVRF(name string) bool {
+	for _, line := range strings.Split(readSysfs(name, "uevent"), "\n") {
+		if line == "DEVTYPE=vrf" {
+			return true
+		}
+	}
+	return false
+}
+
+func (v vrfDevice) tags() map[string]string {
+	tags := map[string]string{"vrf": v.name}
+	if v.table != "" {
+		tags["vrf_table"] = v.table
+	}
+	return tags
+}
+
+// vrfTags describes interfaces enslaved to VRF devices by VRF name and table
+func vrfTags(links []link) map[string]map[string]string {
+	vrfs := vrfMasters(links)
+	tags := map[string]map[string]string{}
+	for _, l := range links {
+		if v, ok := vrfs[l.master]; ok && l.master != 0 {
+			tags[l.name] = v.tags()
+		}
+	}
+	return tags
+}
+
+// getVRFStats sums statistics of interfaces enslaved to each VRF device
+// and stores them in stats map under vrfKey
+func getVRFStats(stats map[string]interface{}, cfg config, _ *sourceState) error {
+	links, err := getLinks()
+	if err != nil {
+		return err
+	}
+	stats[vrfKey] = vrfStats(links, stats)
+	return nil
+}
+
+// vrfStats returns summed statistics per VRF name, VRF devices themselves are
+// not counted as their traffic passes also through enslaved interfaces,
+// they only give names of published statistics
+func vrfStats(links []link, stats map[string]interface{}) map[string]interface{} {
+	vrfs := vrfMasters(links)
+	res := map[string]interface{}{}
+	for index, v := range vrfs {
+		vstats := map[string]interface{}{}
+		own, _ := stats[v.name].(map[string]interface{})
+		for name, val := range own {
+			if _, ok := val.(int64); !ok {
+				continue
+			}
+			sum := int64(0)
+			for _, l := range links {
+				if l.master != index {
+					continue
+				}
+				istats, _ := stats[l.name].(map[string]interface{})
+				if val, ok := istats[name].(int64); ok && val >= 0 {
+					sum += val
+				}
+			}
+			vstats[name] = taggedValue{value: sum, tags: v.tags()}
+		}
+		res[v.name] = vstats
+	}
+	return res
+}
@@ -0,0 +1,93 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iface
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVRFTags(t *testing.T) {
+	Convey("Given recorded link dump with VRF devices", t, func() {
+		sysClassNet = "../examples/test/sys/class/net"
+		defer func() { sysClassNet = "/sys/class/net" }()
+		links, err := readLinks("../examples/test/rtnl.vrf.links")
+		So(err, ShouldBeNil)
+
+		Convey("When VRF tags are read", func() {
+			tags := vrfTags(links)
+
+			Convey("Enslaved interfaces are tagged with VRF name and table", func() {
+				So(tags, ShouldResemble, map[string]map[string]string{
+					"eth1": {"vrf": "vrf-blue", "vrf_table": "10"},
+					"eth2": {"vrf": "vrf-blue", "vrf_table": "10"},
+					"eth4": {"vrf": "vrf-old"},
+				})
+			})
+		})
+	})
+}
+
+func TestVRFStats(t *testing.T) {
+	Convey("Given interfaces enslaved to VRF devices", t, func() {
+		sysClassNet = "../examples/test/sys/class/net"
+		defer func() { sysClassNet = "/sys/class/net" }()
+		links, err := readLinks("../examples/test/rtnl.vrf.links")
+		So(err, ShouldBeNil)
+
+		iface := func(bytes, errs int64) map[string]interface{} {
+			return map[string]interface{}{"bytes_recv": bytes, "errs_recv": errs}
+		}
+		stats := map[string]interface{}{
+			"lo":       iface(1000, 0),
+			"vrf-blue": iface(7, 0),
+			"eth1":     iface(100, 2),
+			"eth2":     iface(50, -1),
+			"eth3":     iface(30, 0),
+			"vrf-red":  iface(0, 0),
+			"vrf-old":  iface(0, 0),
+			"eth4":     iface(20, 1),
+		}
+
+		Convey("When VRF statistics are calculated", func() {
+			vrfs := vrfStats(links, stats)
+			stat := func(vrf, name string) taggedValue {
+				return vrfs[vrf].(map[string]interface{})[name].(taggedValue)
+			}
+
+			Convey("Statistics of enslaved interfaces are summed", func() {
+				So(stat("vrf-blue", "bytes_recv").value, ShouldEqual, 150)
+				So(stat("vrf-blue", "bytes_recv").tags, ShouldResemble, map[string]string{"vrf": "vrf-blue", "vrf_table": "10"})
+				So(stat("vrf-old", "bytes_recv").value, ShouldEqual, 20)
+			})
+
+			Convey("Unavailable values are skipped", func() {
+				So(stat("vrf-blue", "errs_recv").value, ShouldEqual, 2)
+			})
+
+			Convey("VRF without interfaces is published with zeros", func() {
+				So(stat("vrf-red", "bytes_recv").value, ShouldEqual, 0)
+			})
+		})
+	})
+}
@@ -22,7 +22,9 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/intelsdi-x/snap/control/plugin"
 
@@ -30,6 +32,30 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "manifest" {
+		if err := manifest(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		if err := validate(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// snap passes JSON request as the only argument, flags select standalone mode
+	if len(os.Args) > 1 && strings.HasPrefix(os.Args[1], "-") {
+		if err := standalone(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	ifacePlugin := iface.New()
 	if ifacePlugin == nil {
 		panic("Failed to initialize plugin!\n")
@@ -0,0 +1,246 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+
+	"github.com/intelsdi-x/snap-plugin-collector-interface/iface"
+)
+
+// taskManifest is snap task manifest
+type taskManifest struct {
+	Version  int          `json:"version" yaml:"version"`
+	Schedule taskSchedule `json:"schedule" yaml:"schedule"`
+	Workflow taskWorkflow `json:"workflow" yaml:"workflow"`
+}
+
+type taskSchedule struct {
+	Type     string `json:"type" yaml:"type"`
+	Interval string `json:"interval" yaml:"interval"`
+}
+
+type taskWorkflow struct {
+	Collect taskCollect `json:"collect" yaml:"collect"`
+}
+
+type taskCollect struct {
+	Metrics map[string]struct{}               `json:"metrics" yaml:"metrics"`
+	Config  map[string]map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"`
+	Process []taskNode                        `json:"process,omitempty" yaml:"process,omitempty"`
+	Publish []taskNode                        `json:"publish,omitempty" yaml:"publish,omitempty"`
+}
+
+// taskNode is processor or publisher of task workflow
+type taskNode struct {
+	PluginName string                 `json:"plugin_name" yaml:"plugin_name"`
+	Config     map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"`
+	Publish    []taskNode             `json:"publish,omitempty" yaml:"publish,omitempty"`
+}
+
+// configItems is repeatable key=value flag
+type configItems map[string]interface{}
+
+func (c configItems) String() string {
+	items := []string{}
+	for k, v := range c {
+		items = append(items, fmt.Sprintf("%s=%v", k, v))
+	}
+	return strings.Join(items, ",")
+}
+
+// Set adds config item, value is bool when it is true or false,
+// integer when it is a number and string otherwise
+func (c configItems) Set(item string) error {
+	kv := strings.SplitN(item, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("Wrong config item {%s}, expected key=value", item)
+	}
+	if kv[1] == "true" || kv[1] == "false" {
+		c[kv[0]] = kv[1] == "true"
+	} else if i, err := strconv.Atoi(kv[1]); err == nil {
+		c[kv[0]] = i
+	} else {
+		c[kv[0]] = kv[1]
+	}
+	return nil
+}
+
+// node returns config items as plugin config node
+func (c configItems) node() *cdata.ConfigDataNode {
+	node := cdata.NewNode()
+	for k, v := range c {
+		switch v := v.(type) {
+		case bool:
+			node.AddItem(k, ctypes.ConfigValueBool{Value: v})
+		case int:
+			node.AddItem(k, ctypes.ConfigValueInt{Value: v})
+		case string:
+			node.AddItem(k, ctypes.ConfigValueStr{Value: v})
+		}
+	}
+	return node
+}
+
+// manifestOptions select metrics and workflow of generated manifest
+type manifestOptions struct {
+	interfaces      []string
+	stats           []string
+	interval        time.Duration
+	config          configItems
+	processor       string
+	publisher       string
+	publisherConfig configItems
+}
+
+// manifest writes task manifest built from catalog of the plugin
+func manifest(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("manifest", flag.ContinueOnError)
+	opts := manifestOptions{config: configItems{}, publisherConfig: configItems{}}
+	interfaces := fs.String("interfaces", "*", "comma separated interface name patterns, e.g. eth*,lo")
+	stats := fs.String("stats", "*", "comma separated statistic patterns, e.g. bytes_*,errs_recv")
+	format := fs.String("format", "json", "manifest format, json or yaml")
+	output := fs.String("o", "", "output file, defaults to standard output")
+	fs.DurationVar(&opts.interval, "interval", time.Second, "collection interval")
+	fs.Var(opts.config, "config", "plugin config item key=value, may be repeated")
+	fs.StringVar(&opts.processor, "processor", "", "processor plugin name, metrics are published directly when empty")
+	fs.StringVar(&opts.publisher, "publisher", "file", "publisher plugin name")
+	fs.Var(opts.publisherConfig, "publisher-config", "publisher config item key=value, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts.interfaces = strings.Split(*interfaces, ",")
+	opts.stats = strings.Split(*stats, ",")
+	if len(opts.publisherConfig) == 0 && opts.publisher == "file" {
+		opts.publisherConfig["file"] = "/tmp/published_interface"
+	}
+
+	ifacePlugin := iface.New()
+	if ifacePlugin == nil {
+		return fmt.Errorf("Failed to initialize plugin")
+	}
+	mts, err := ifacePlugin.GetMetricTypes(plugin.PluginConfigType{ConfigDataNode: opts.config.node()})
+	if err != nil {
+		return err
+	}
+	task, err := buildManifest(mts, opts)
+	if err != nil {
+		return err
+	}
+
+	var b []byte
+	switch *format {
+	case "json":
+		if b, err = json.MarshalIndent(task, "", "    "); err == nil {
+			b = append(b, '\n')
+		}
+	case "yaml":
+		b, err = yaml.Marshal(task)
+	default:
+		err = fmt.Errorf("Unknown manifest format {%s}", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	_, err = out.Write(b)
+	return err
+}
+
+// buildManifest returns task collecting catalog metrics which match interface
+// and statistic patterns, statistic patterns match namespace after interface
+func buildManifest(mts []plugin.PluginMetricType, opts manifestOptions) (*taskManifest, error) {
+	metrics := map[string]struct{}{}
+	for _, mt := range mts {
+		ns := mt.Namespace()
+		if len(ns) < 5 {
+			continue
+		}
+		if matchAny(opts.interfaces, ns[3]) && matchAny(opts.stats, strings.Join(ns[4:], "/")) {
+			metrics["/"+strings.Join(ns, "/")] = struct{}{}
+		}
+	}
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("No metrics of catalog match interfaces %v and statistics %v", opts.interfaces, opts.stats)
+	}
+	if opts.interval <= 0 {
+		return nil, fmt.Errorf("Wrong collection interval {%v}", opts.interval)
+	}
+
+	task := &taskManifest{
+		Version:  1,
+		Schedule: taskSchedule{Type: "simple", Interval: opts.interval.String()},
+	}
+	task.Workflow.Collect.Metrics = metrics
+	if len(opts.config) > 0 {
+		task.Workflow.Collect.Config = map[string]map[string]interface{}{
+			"/" + strings.Join([]string{iface.VENDOR, iface.FS, iface.PLUGIN}, "/"): opts.config,
+		}
+		// network namespace totals are published next to the plugin namespace
+		totals := "/" + strings.Join([]string{iface.VENDOR, iface.FS, iface.NETNS}, "/")
+		for m := range metrics {
+			if strings.HasPrefix(m, totals+"/") {
+				task.Workflow.Collect.Config[totals] = opts.config
+				break
+			}
+		}
+	}
+	publish := []taskNode{{PluginName: opts.publisher, Config: opts.publisherConfig}}
+	if opts.processor != "" {
+		task.Workflow.Collect.Process = []taskNode{{PluginName: opts.processor, Publish: publish}}
+	} else {
+		task.Workflow.Collect.Publish = publish
+	}
+	return task, nil
+}
+
+// matchAny tells whether name matches any of shell patterns
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(strings.TrimSpace(p), name); ok {
+			return true
+		}
+	}
+	return false
+}
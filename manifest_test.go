@@ -0,0 +1,145 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/yaml.v2"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+func TestBuildManifest(t *testing.T) {
+	Convey("Given catalog of metrics", t, func() {
+		mts := []plugin.PluginMetricType{}
+		for _, ns := range []string{"eth0/bytes_recv", "eth0/errs_recv", "eth1/bytes_recv", "lo/bytes_recv", "can0/can/state", "_tcp/port/22/rtt_p50"} {
+			mts = append(mts, plugin.PluginMetricType{Namespace_: strings.Split("intel/procfs/iface/"+ns, "/")})
+		}
+		opts := manifestOptions{
+			interfaces:      []string{"eth*", "can0"},
+			stats:           []string{"bytes_*", "can/*"},
+			interval:        5 * time.Second,
+			config:          configItems{},
+			publisher:       "file",
+			publisherConfig: configItems{"file": "/tmp/published_interface"},
+		}
+
+		Convey("When manifest is built", func() {
+			task, err := buildManifest(mts, opts)
+			So(err, ShouldBeNil)
+
+			Convey("Metrics matching interface and statistic patterns are collected", func() {
+				So(task.Workflow.Collect.Metrics, ShouldResemble, map[string]struct{}{
+					"/intel/procfs/iface/eth0/bytes_recv": {},
+					"/intel/procfs/iface/eth1/bytes_recv": {},
+					"/intel/procfs/iface/can0/can/state":  {},
+				})
+				So(task.Schedule, ShouldResemble, taskSchedule{Type: "simple", Interval: "5s"})
+			})
+
+			Convey("Metrics are published directly", func() {
+				So(task.Workflow.Collect.Process, ShouldBeEmpty)
+				So(task.Workflow.Collect.Publish, ShouldResemble, []taskNode{{PluginName: "file", Config: map[string]interface{}{"file": "/tmp/published_interface"}}})
+			})
+		})
+
+		Convey("When processor and plugin config are set", func() {
+			opts.processor = "passthru"
+			So(opts.config.Set("tcp_info=true"), ShouldBeNil)
+			So(opts.config.Set("proc_roots=vm1=/mnt/vm1/proc"), ShouldBeNil)
+			task, err := buildManifest(mts, opts)
+			So(err, ShouldBeNil)
+
+			Convey("Processor publishes metrics", func() {
+				So(task.Workflow.Collect.Publish, ShouldBeEmpty)
+				So(task.Workflow.Collect.Process[0].PluginName, ShouldEqual, "passthru")
+				So(task.Workflow.Collect.Process[0].Publish[0].PluginName, ShouldEqual, "file")
+			})
+
+			Convey("Plugin config is added to the task", func() {
+				So(task.Workflow.Collect.Config["/intel/procfs/iface"], ShouldResemble, map[string]interface{}{
+					"tcp_info":   true,
+					"proc_roots": "vm1=/mnt/vm1/proc",
+				})
+			})
+		})
+
+		Convey("When no metric matches", func() {
+			opts.interfaces = []string{"wlan*"}
+			_, err := buildManifest(mts, opts)
+
+			Convey("Error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestManifest(t *testing.T) {
+	Convey("Given live catalog of loopback interface", t, func() {
+		Convey("When JSON manifest is written", func() {
+			var out bytes.Buffer
+			err := manifest([]string{"-interfaces", "lo", "-stats", "errs_*", "-interval", "10s"}, &out)
+			So(err, ShouldBeNil)
+
+			Convey("It is valid task manifest", func() {
+				task := taskManifest{}
+				So(json.Unmarshal(out.Bytes(), &task), ShouldBeNil)
+				So(task.Version, ShouldEqual, 1)
+				So(task.Schedule.Interval, ShouldEqual, "10s")
+				So(task.Workflow.Collect.Metrics, ShouldResemble, map[string]struct{}{
+					"/intel/procfs/iface/lo/errs_recv": {},
+					"/intel/procfs/iface/lo/errs_sent": {},
+				})
+				So(task.Workflow.Collect.Publish[0].Config["file"], ShouldEqual, "/tmp/published_interface")
+			})
+		})
+
+		Convey("When YAML manifest is written", func() {
+			var out bytes.Buffer
+			err := manifest([]string{"-interfaces", "lo", "-stats", "bytes_recv", "-format", "yaml", "-publisher", "influx", "-publisher-config", "port=8086"}, &out)
+			So(err, ShouldBeNil)
+
+			Convey("It is valid task manifest", func() {
+				task := taskManifest{}
+				So(yaml.Unmarshal(out.Bytes(), &task), ShouldBeNil)
+				So(task.Workflow.Collect.Metrics, ShouldContainKey, "/intel/procfs/iface/lo/bytes_recv")
+				So(task.Workflow.Collect.Publish[0].PluginName, ShouldEqual, "influx")
+				So(task.Workflow.Collect.Publish[0].Config["port"], ShouldEqual, 8086)
+			})
+		})
+
+		Convey("When unknown format is requested", func() {
+			err := manifest([]string{"-format", "xml"}, &bytes.Buffer{})
+
+			Convey("Error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,105 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http2"
+)
+
+// grpcExportPath is method of OTLP metrics service
+const grpcExportPath = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+// grpcTransport sends requests over HTTP/2, with TLS when it is set and
+// with prior knowledge over plain connection otherwise
+func grpcTransport(secure bool) http.RoundTripper {
+	if secure {
+		return &http2.Transport{}
+	}
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.DialTimeout(network, addr, Timeout)
+		},
+	}
+}
+
+// grpcCall sends unary gRPC request and returns response message, call
+// fails unless grpc-status of trailers or of trailers-only response is OK
+func grpcCall(client *http.Client, target, path string, msg []byte) ([]byte, error) {
+	// gRPC message is prefixed by compression flag and length
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+
+	req, err := http.NewRequest("POST", target+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/grpc")
+	req.Header.Set("te", "trailers")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gRPC call {%s} failed with HTTP status %d", path, resp.StatusCode)
+	}
+	// trailers are set once body is read
+	response, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	status, trailer := resp.Trailer.Get("grpc-status"), resp.Trailer
+	if status == "" {
+		status, trailer = resp.Header.Get("grpc-status"), resp.Header
+	}
+	switch status {
+	case "0":
+	case "":
+		return nil, fmt.Errorf("gRPC call {%s} ended without status", path)
+	default:
+		// grpc-message is percent-encoded
+		message := trailer.Get("grpc-message")
+		if m, err := url.PathUnescape(message); err == nil {
+			message = m
+		}
+		return nil, fmt.Errorf("OTLP receiver rejected export with gRPC status %s {%s}", status, message)
+	}
+
+	if len(response) < 5 {
+		return nil, fmt.Errorf("gRPC call {%s} failed, response has no message", path)
+	}
+	l := binary.BigEndian.Uint32(response[1:5])
+	if response[0] != 0 || uint32(len(response)-5) < l {
+		return nil, fmt.Errorf("Wrong gRPC response message of {%s}", path)
+	}
+	return response[5 : 5+l], nil
+}
@@ -0,0 +1,196 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// receivedCall is export call as OTLP/gRPC receiver stand-in got it
+type receivedCall struct {
+	path        string
+	contentType string
+	request     *request
+	err         error
+}
+
+// grpcReceiver is OTLP/gRPC receiver stand-in served by x/net/http2 with
+// prior knowledge, it answers with status and message, in trailers-only
+// response when trailersOnly is set
+type grpcReceiver struct {
+	ln           net.Listener
+	status       string
+	message      string
+	trailersOnly bool
+	calls        chan receivedCall
+}
+
+func newGRPCReceiver(status, message string, trailersOnly bool) (*grpcReceiver, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	r := &grpcReceiver{ln: ln, status: status, message: message, trailersOnly: trailersOnly, calls: make(chan receivedCall, 1)}
+	go r.serve()
+	return r, nil
+}
+
+func (r *grpcReceiver) serve() {
+	server := &http2.Server{}
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return
+		}
+		go server.ServeConn(conn, &http2.ServeConnOpts{Handler: r})
+	}
+}
+
+// call returns export call which receiver got, errors of its decoding are reported to the test
+func (r *grpcReceiver) call(t *testing.T) receivedCall {
+	c := <-r.calls
+	if c.err != nil {
+		t.Error("gRPC receiver:", c.err)
+	}
+	return c
+}
+
+func (r *grpcReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c := receivedCall{path: req.URL.Path, contentType: req.Header.Get("content-type")}
+	body, err := ioutil.ReadAll(req.Body)
+	switch {
+	case err != nil:
+		c.err = err
+	case len(body) < 5 || body[0] != 0 || int(binary.BigEndian.Uint32(body[1:])) != len(body)-5:
+		c.err = fmt.Errorf("Wrong gRPC message of %d bytes", len(body))
+	default:
+		c.request, c.err = decodeRequest(body[5:])
+	}
+	r.calls <- c
+
+	w.Header().Set("content-type", "application/grpc")
+	if r.trailersOnly {
+		w.Header().Set("grpc-status", r.status)
+		w.Header().Set("grpc-message", r.message)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Trailer", "grpc-status, grpc-message")
+	w.WriteHeader(http.StatusOK)
+	if r.status == "0" {
+		// empty ExportMetricsServiceResponse
+		w.Write(make([]byte, 5))
+	}
+	w.Header().Set("grpc-status", r.status)
+	w.Header().Set("grpc-message", r.message)
+}
+
+func TestGRPCExporter(t *testing.T) {
+	Convey("Given OTLP/gRPC receiver stand-in", t, func() {
+		rcv, err := newGRPCReceiver("0", "", false)
+		So(err, ShouldBeNil)
+		defer rcv.ln.Close()
+
+		exporter, err := NewExporter(rcv.ln.Addr().String(), ProtocolGRPC, resource)
+		So(err, ShouldBeNil)
+		defer exporter.Close()
+
+		Convey("When metrics are exported", func() {
+			err := exporter.Export(metrics)
+
+			Convey("Receiver gets export call", func() {
+				So(err, ShouldBeNil)
+				call := rcv.call(t)
+				So(call.path, ShouldEqual, grpcExportPath)
+				So(call.contentType, ShouldEqual, "application/grpc")
+				checkRequest(call.request)
+			})
+		})
+
+		Convey("When request exceeds HTTP/2 frame and window sizes", func() {
+			many := []Metric{}
+			for i := 0; i < 2000; i++ {
+				m := metrics[0]
+				m.Name = fmt.Sprintf("system.network.%s%d", strings.Repeat("x", 50), i)
+				many = append(many, m)
+			}
+			err := exporter.Export(many)
+
+			Convey("It is sent whole", func() {
+				So(err, ShouldBeNil)
+				call := rcv.call(t)
+				So(call.request, ShouldNotBeNil)
+				So(call.request.metrics, ShouldHaveLength, 2000)
+			})
+		})
+	})
+
+	Convey("Given OTLP/gRPC receiver stand-in which rejects calls in trailers-only response", t, func() {
+		rcv, err := newGRPCReceiver("3", "bad data%3A points", true)
+		So(err, ShouldBeNil)
+		defer rcv.ln.Close()
+
+		exporter, err := NewExporter(rcv.ln.Addr().String(), ProtocolGRPC, resource)
+		So(err, ShouldBeNil)
+		defer exporter.Close()
+
+		Convey("When metrics are exported", func() {
+			err := exporter.Export(metrics)
+			rcv.call(t)
+
+			Convey("Error has decoded status and message", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "gRPC status 3 {bad data: points}")
+			})
+		})
+	})
+
+	Convey("Given OTLP/gRPC receiver stand-in which rejects calls in trailers", t, func() {
+		rcv, err := newGRPCReceiver("14", "unavailable", false)
+		So(err, ShouldBeNil)
+		defer rcv.ln.Close()
+
+		exporter, err := NewExporter(rcv.ln.Addr().String(), ProtocolGRPC, resource)
+		So(err, ShouldBeNil)
+		defer exporter.Close()
+
+		Convey("When metrics are exported", func() {
+			err := exporter.Export(metrics)
+			rcv.call(t)
+
+			Convey("Error has status of trailers", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "gRPC status 14 {unavailable}")
+			})
+		})
+	})
+}
@@ -0,0 +1,371 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package otlp encodes OpenTelemetry metrics into OTLP export requests and
+// sends them to collector over gRPC or HTTP
+package otlp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ProtocolGRPC sends requests as unary gRPC calls over HTTP/2
+	ProtocolGRPC = "grpc"
+	// ProtocolHTTP posts protobuf encoded requests over HTTP/1.1
+	ProtocolHTTP = "http"
+
+	// DefaultGRPCPort of OTLP receivers
+	DefaultGRPCPort = "4317"
+	// DefaultHTTPPort of OTLP receivers
+	DefaultHTTPPort = "4318"
+	// HTTPPath of metrics export
+	HTTPPath = "/v1/metrics"
+
+	// Timeout of single export
+	Timeout = 10 * time.Second
+
+	// ScopeName is instrumentation scope of exported metrics
+	ScopeName = "snap-plugin-collector-interface"
+
+	temporalityCumulative = 2
+)
+
+// Kind tells how metric points aggregate
+type Kind int
+
+const (
+	// Gauge is value sampled at point time, e.g. rate
+	Gauge Kind = iota
+	// Sum is monotonic counter accumulated since point start time
+	Sum
+)
+
+// Metric is named set of points of one kind
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+	Kind        Kind
+	Points      []Point
+}
+
+// Point is value of metric for one set of attributes, value is int64 or float64
+type Point struct {
+	Attributes map[string]string
+	// Start is time counting of Sum started
+	Start time.Time
+	Time  time.Time
+	Value interface{}
+}
+
+// Exporter sends metrics with resource attributes to OTLP receiver
+type Exporter struct {
+	protocol string
+	// target is URL of HTTP requests or scheme and address of gRPC receiver
+	target   string
+	tls      bool
+	resource map[string]string
+	client   *http.Client
+}
+
+// NewExporter creates exporter of given protocol, endpoint is host[:port]
+// or URL with http or https scheme; default port of protocol is used when
+// endpoint has none and HTTP requests go to HTTPPath unless URL has path
+func NewExporter(endpoint, protocol string, resource map[string]string) (*Exporter, error) {
+	e := &Exporter{protocol: protocol, resource: resource}
+	port := DefaultGRPCPort
+	switch protocol {
+	case ProtocolGRPC:
+	case ProtocolHTTP:
+		port = DefaultHTTPPort
+	default:
+		return nil, fmt.Errorf("Unknown OTLP protocol {%s}, expected %s or %s", protocol, ProtocolGRPC, ProtocolHTTP)
+	}
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("Wrong OTLP endpoint {%s}, %v", endpoint, err)
+	}
+	switch u.Scheme {
+	case "http":
+	case "https":
+		e.tls = true
+	default:
+		return nil, fmt.Errorf("Wrong scheme of OTLP endpoint {%s}, expected http or https", endpoint)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("Wrong OTLP endpoint {%s}, host is missing", endpoint)
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		u.Host = net.JoinHostPort(u.Host, port)
+	}
+
+	if protocol == ProtocolGRPC {
+		e.client = &http.Client{Timeout: Timeout, Transport: grpcTransport(e.tls)}
+		e.target = u.Scheme + "://" + u.Host
+		return e, nil
+	}
+	e.client = &http.Client{Timeout: Timeout}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = HTTPPath
+	}
+	e.target = u.String()
+	return e, nil
+}
+
+// Export sends metrics in single export request
+func (e *Exporter) Export(metrics []Metric) error {
+	req := encodeRequest(e.resource, metrics)
+	var resp []byte
+	var err error
+	if e.protocol == ProtocolGRPC {
+		resp, err = e.exportGRPC(req)
+	} else {
+		resp, err = e.exportHTTP(req)
+	}
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp)
+}
+
+// Close releases idle connections of exporter
+func (e *Exporter) Close() error {
+	if t, ok := e.client.Transport.(interface {
+		CloseIdleConnections()
+	}); ok {
+		t.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (e *Exporter) exportHTTP(req []byte) ([]byte, error) {
+	resp, err := e.client.Post(e.target, "application/x-protobuf", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		// body of failed request is google.rpc.Status
+		msg := ""
+		forEachField(body, func(field, wire int, _ uint64, data []byte) error {
+			if field == 2 && wire == wireBytes {
+				msg = string(data)
+			}
+			return nil
+		})
+		return nil, fmt.Errorf("OTLP receiver rejected export with HTTP status %d {%s}", resp.StatusCode, msg)
+	}
+	return body, nil
+}
+
+func (e *Exporter) exportGRPC(req []byte) ([]byte, error) {
+	return grpcCall(e.client, e.target, grpcExportPath, req)
+}
+
+// decodeResponse reports points which receiver rejected
+func decodeResponse(b []byte) error {
+	return forEachField(b, func(field, wire int, _ uint64, data []byte) error {
+		if field != 1 || wire != wireBytes {
+			return nil
+		}
+		rejected, msg := uint64(0), ""
+		forEachField(data, func(field, wire int, v uint64, data []byte) error {
+			switch {
+			case field == 1 && wire == wireVarint:
+				rejected = v
+			case field == 2 && wire == wireBytes:
+				msg = string(data)
+			}
+			return nil
+		})
+		if rejected > 0 || msg != "" {
+			return fmt.Errorf("OTLP receiver rejected %d data points {%s}", rejected, msg)
+		}
+		return nil
+	})
+}
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field, wire int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func appendBytes(b []byte, field int, data []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendString(b []byte, field int, s string) []byte {
+	return appendBytes(b, field, []byte(s))
+}
+
+func appendFixed64(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// forEachField calls fn with fields of protobuf message, v holds varint and
+// fixed values and data holds length delimited ones
+func forEachField(b []byte, fn func(field, wire int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("Wrong protobuf field key")
+		}
+		b = b[n:]
+		field, wire := int(key>>3), int(key&7)
+		var v uint64
+		var data []byte
+		switch wire {
+		case wireVarint:
+			if v, n = binary.Uvarint(b); n <= 0 {
+				return fmt.Errorf("Wrong protobuf varint of field %d", field)
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return fmt.Errorf("Truncated protobuf field %d", field)
+			}
+			v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return fmt.Errorf("Truncated protobuf field %d", field)
+			}
+			v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return fmt.Errorf("Truncated protobuf field %d", field)
+			}
+			data, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return fmt.Errorf("Unsupported protobuf wire type %d of field %d", wire, field)
+		}
+		if err := fn(field, wire, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeRequest encodes ExportMetricsServiceRequest with single resource and scope
+func encodeRequest(resource map[string]string, metrics []Metric) []byte {
+	res := encodeAttributes(nil, 1, resource)
+
+	scope := appendString(nil, 1, ScopeName)
+	scopeMetrics := appendBytes(nil, 1, scope)
+	for _, m := range metrics {
+		scopeMetrics = appendBytes(scopeMetrics, 2, encodeMetric(m))
+	}
+
+	rm := appendBytes(nil, 1, res)
+	rm = appendBytes(rm, 2, scopeMetrics)
+	return appendBytes(nil, 1, rm)
+}
+
+func encodeMetric(m Metric) []byte {
+	b := appendString(nil, 1, m.Name)
+	if m.Description != "" {
+		b = appendString(b, 2, m.Description)
+	}
+	if m.Unit != "" {
+		b = appendString(b, 3, m.Unit)
+	}
+
+	data := []byte{}
+	for _, p := range m.Points {
+		data = appendBytes(data, 1, encodePoint(p, m.Kind == Sum))
+	}
+	if m.Kind == Sum {
+		data = appendTag(data, 2, wireVarint)
+		data = appendVarint(data, temporalityCumulative)
+		data = appendTag(data, 3, wireVarint)
+		data = appendVarint(data, 1)
+		return appendBytes(b, 7, data)
+	}
+	return appendBytes(b, 5, data)
+}
+
+// encodePoint encodes NumberDataPoint
+func encodePoint(p Point, withStart bool) []byte {
+	b := []byte{}
+	if withStart && !p.Start.IsZero() {
+		b = appendFixed64(b, 2, uint64(p.Start.UnixNano()))
+	}
+	b = appendFixed64(b, 3, uint64(p.Time.UnixNano()))
+	switch v := p.Value.(type) {
+	case float64:
+		b = appendFixed64(b, 4, math.Float64bits(v))
+	case int64:
+		b = appendFixed64(b, 6, uint64(v))
+	}
+	return encodeAttributes(b, 7, p.Attributes)
+}
+
+// encodeAttributes appends attributes as KeyValue fields in key order
+func encodeAttributes(b []byte, field int, attrs map[string]string) []byte {
+	keys := []string{}
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kv := appendString(nil, 1, k)
+		kv = appendBytes(kv, 2, appendString(nil, 1, attrs[k]))
+		b = appendBytes(b, field, kv)
+	}
+	return b
+}
@@ -0,0 +1,289 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// request is decoded ExportMetricsServiceRequest
+type request struct {
+	resource map[string]string
+	scope    string
+	metrics  []decodedMetric
+}
+
+type decodedMetric struct {
+	name        string
+	unit        string
+	sum         bool
+	temporality uint64
+	monotonic   bool
+	points      []Point
+}
+
+func decodeAttribute(b []byte, attrs map[string]string) error {
+	key, value := "", ""
+	err := forEachField(b, func(field, wire int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			key = string(data)
+		case 2:
+			return forEachField(data, func(field, wire int, v uint64, data []byte) error {
+				value = string(data)
+				return nil
+			})
+		}
+		return nil
+	})
+	attrs[key] = value
+	return err
+}
+
+func decodePoint(b []byte) (Point, error) {
+	p := Point{Attributes: map[string]string{}}
+	err := forEachField(b, func(field, wire int, v uint64, data []byte) error {
+		switch field {
+		case 2:
+			p.Start = time.Unix(0, int64(v))
+		case 3:
+			p.Time = time.Unix(0, int64(v))
+		case 4:
+			p.Value = math.Float64frombits(v)
+		case 6:
+			p.Value = int64(v)
+		case 7:
+			return decodeAttribute(data, p.Attributes)
+		}
+		return nil
+	})
+	return p, err
+}
+
+func decodeMetric(b []byte) (decodedMetric, error) {
+	m := decodedMetric{}
+	err := forEachField(b, func(field, wire int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			m.name = string(data)
+		case 3:
+			m.unit = string(data)
+		case 5, 7:
+			m.sum = field == 7
+			return forEachField(data, func(field, wire int, v uint64, data []byte) error {
+				switch field {
+				case 1:
+					p, err := decodePoint(data)
+					m.points = append(m.points, p)
+					return err
+				case 2:
+					m.temporality = v
+				case 3:
+					m.monotonic = v == 1
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return m, err
+}
+
+func decodeRequest(b []byte) (*request, error) {
+	r := &request{resource: map[string]string{}}
+	err := forEachField(b, func(field, wire int, v uint64, rm []byte) error {
+		return forEachField(rm, func(field, wire int, v uint64, data []byte) error {
+			if field == 1 {
+				return forEachField(data, func(field, wire int, v uint64, kv []byte) error {
+					return decodeAttribute(kv, r.resource)
+				})
+			}
+			return forEachField(data, func(field, wire int, v uint64, data []byte) error {
+				if field == 1 {
+					return forEachField(data, func(field, wire int, v uint64, name []byte) error {
+						r.scope = string(name)
+						return nil
+					})
+				}
+				m, err := decodeMetric(data)
+				r.metrics = append(r.metrics, m)
+				return err
+			})
+		})
+	})
+	return r, err
+}
+
+var (
+	start = time.Unix(1475000000, 0)
+	now   = time.Unix(1475000600, 0)
+	// metrics are sample of those exported by standalone mode
+	metrics = []Metric{
+		{
+			Name: "system.network.io",
+			Unit: "By",
+			Kind: Sum,
+			Points: []Point{
+				{Attributes: map[string]string{"network.interface.name": "eth0", "network.io.direction": "receive"}, Start: start, Time: now, Value: int64(1412848320)},
+				{Attributes: map[string]string{"network.interface.name": "eth0", "network.io.direction": "transmit"}, Start: start, Time: now, Value: int64(6434234393)},
+			},
+		},
+		{
+			Name:   "system.network.io.rate",
+			Unit:   "By/s",
+			Kind:   Gauge,
+			Points: []Point{{Attributes: map[string]string{"network.interface.name": "eth0", "network.io.direction": "receive"}, Time: now, Value: 1250.5}},
+		},
+	}
+	resource = map[string]string{"host.name": "node1", "service.name": "snap-plugin-collector-interface"}
+)
+
+// checkRequest asserts that request carries exported metrics
+func checkRequest(r *request) {
+	So(r.resource, ShouldResemble, resource)
+	So(r.scope, ShouldEqual, ScopeName)
+	So(r.metrics, ShouldHaveLength, 2)
+
+	So(r.metrics[0].name, ShouldEqual, "system.network.io")
+	So(r.metrics[0].unit, ShouldEqual, "By")
+	So(r.metrics[0].sum, ShouldBeTrue)
+	So(r.metrics[0].temporality, ShouldEqual, temporalityCumulative)
+	So(r.metrics[0].monotonic, ShouldBeTrue)
+	So(r.metrics[0].points, ShouldHaveLength, 2)
+	So(r.metrics[0].points[1].Attributes, ShouldResemble, metrics[0].Points[1].Attributes)
+	So(r.metrics[0].points[1].Value, ShouldEqual, 6434234393)
+	So(r.metrics[0].points[1].Start.Equal(start), ShouldBeTrue)
+	So(r.metrics[0].points[1].Time.Equal(now), ShouldBeTrue)
+
+	So(r.metrics[1].sum, ShouldBeFalse)
+	So(r.metrics[1].points[0].Value, ShouldEqual, 1250.5)
+	So(r.metrics[1].points[0].Start.IsZero(), ShouldBeTrue)
+}
+
+func TestNewExporter(t *testing.T) {
+	Convey("Given OTLP endpoints", t, func() {
+		Convey("Default port and path of protocol are added", func() {
+			e, err := NewExporter("collector", ProtocolGRPC, nil)
+			So(err, ShouldBeNil)
+			So(e.target, ShouldEqual, "http://collector:4317")
+			e, err = NewExporter("https://collector", ProtocolHTTP, nil)
+			So(err, ShouldBeNil)
+			So(e.target, ShouldEqual, "https://collector:4318/v1/metrics")
+			So(e.tls, ShouldBeTrue)
+			e, err = NewExporter("http://collector:8080/otlp/metrics", ProtocolHTTP, nil)
+			So(err, ShouldBeNil)
+			So(e.target, ShouldEqual, "http://collector:8080/otlp/metrics")
+		})
+
+		Convey("Wrong endpoints and protocols are rejected", func() {
+			_, err := NewExporter("ftp://collector", ProtocolHTTP, nil)
+			So(err, ShouldNotBeNil)
+			_, err = NewExporter("collector", "udp", nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestHTTPExporter(t *testing.T) {
+	Convey("Given OTLP/HTTP receiver stand-in", t, func() {
+		requests := make(chan *request, 1)
+		status, response := http.StatusOK, []byte{}
+		rcv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.URL.Path != HTTPPath || r.Header.Get("Content-Type") != "application/x-protobuf" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			req, err := decodeRequest(body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			requests <- req
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			w.WriteHeader(status)
+			w.Write(response)
+		}))
+		defer rcv.Close()
+
+		exporter, err := NewExporter(rcv.URL, ProtocolHTTP, resource)
+		So(err, ShouldBeNil)
+		defer exporter.Close()
+
+		Convey("When metrics are exported", func() {
+			err := exporter.Export(metrics)
+
+			Convey("Receiver gets cumulative sums and gauges with attributes", func() {
+				So(err, ShouldBeNil)
+				checkRequest(<-requests)
+			})
+		})
+
+		Convey("When receiver rejects some points", func() {
+			partial := appendTag(nil, 1, wireVarint)
+			partial = appendVarint(partial, 2)
+			partial = appendString(partial, 2, "out of order")
+			response = appendBytes(nil, 1, partial)
+			err := exporter.Export(metrics)
+
+			Convey("Error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "rejected 2 data points {out of order}")
+			})
+		})
+
+		Convey("When receiver fails", func() {
+			status, response = http.StatusServiceUnavailable, appendString(nil, 2, "overloaded")
+			err := exporter.Export(metrics)
+
+			Convey("Status and message are returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "503 {overloaded}")
+			})
+		})
+	})
+}
+
+func TestEncodeRequest(t *testing.T) {
+	Convey("Given request with many points", t, func() {
+		points := []Point{}
+		for i := 0; i < 1000; i++ {
+			points = append(points, Point{Attributes: map[string]string{"network.interface.name": strings.Repeat("x", i%15+1)}, Time: now, Value: int64(i)})
+		}
+		b := encodeRequest(resource, []Metric{{Name: "system.network.packets", Kind: Sum, Points: points}})
+
+		Convey("It is decoded back", func() {
+			r, err := decodeRequest(b)
+			So(err, ShouldBeNil)
+			So(r.metrics[0].points, ShouldHaveLength, 1000)
+			So(r.metrics[0].points[999].Value, ShouldEqual, 999)
+		})
+	})
+}
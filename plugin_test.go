@@ -0,0 +1,173 @@
+// +build integration
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/control/plugin/client"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+
+	"github.com/intelsdi-x/snap-plugin-collector-interface/iface"
+)
+
+// pluginBin is plugin binary built for the tests
+var pluginBin string
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "iface")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	pluginBin = filepath.Join(dir, "snap-plugin-collector-interface")
+	if out, err := exec.Command("go", "build", "-o", pluginBin, ".").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot build plugin, %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// startPlugin starts plugin binary the way snapd does and returns
+// its handshake response, the plugin keeps serving RPC after handshake
+// until it is killed by returned func
+func startPlugin(dir string) (*plugin.Response, func()) {
+	arg, err := json.Marshal(map[string]interface{}{
+		"PluginLogPath": filepath.Join(dir, "plugin.log"),
+	})
+	So(err, ShouldBeNil)
+	cmd := exec.Command(pluginBin, string(arg))
+	stdout, err := cmd.StdoutPipe()
+	So(err, ShouldBeNil)
+	So(cmd.Start(), ShouldBeNil)
+	stop := func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}
+
+	// snapd reads handshake response from the first line of plugin output
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(stdout).ReadString('\n')
+		lines <- line
+	}()
+	var line string
+	select {
+	case line = <-lines:
+	case <-time.After(10 * time.Second):
+		stop()
+		So("plugin response", ShouldEqual, "received in 10s")
+	}
+
+	resp := &plugin.Response{}
+	if err := json.Unmarshal([]byte(line), resp); err != nil {
+		stop()
+		So(err, ShouldBeNil)
+	}
+	return resp, stop
+}
+
+func TestPluginProtocol(t *testing.T) {
+	Convey("Given started plugin binary", t, func() {
+		dir, err := ioutil.TempDir("", "iface")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		resp, stop := startPlugin(dir)
+		defer stop()
+
+		Convey("Handshake describes the plugin", func() {
+			So(resp.State, ShouldEqual, plugin.PluginSuccess)
+			So(resp.ErrorMessage, ShouldBeEmpty)
+			So(resp.Type, ShouldEqual, plugin.CollectorPluginType)
+			So(resp.Meta.Name, ShouldEqual, iface.PLUGIN)
+			So(resp.Meta.Version, ShouldEqual, iface.VERSION)
+			So(resp.Meta.ReturnedContentTypes, ShouldContain, plugin.SnapGOBContentType)
+			So(resp.ListenAddress, ShouldNotBeEmpty)
+		})
+
+		Convey("When client is connected over RPC", func() {
+			c, err := client.NewCollectorNativeClient(resp.ListenAddress, 5*time.Second, resp.PublicKey, !resp.Meta.Unsecure)
+			So(err, ShouldBeNil)
+			if !resp.Meta.Unsecure {
+				So(c.SetKey(), ShouldBeNil)
+			}
+			So(c.Ping(), ShouldBeNil)
+
+			root, err := filepath.Abs("examples/test/roots/vm1")
+			So(err, ShouldBeNil)
+			node := cdata.NewNode()
+			node.AddItem("proc_roots", ctypes.ConfigValueStr{Value: "fixture=" + root})
+
+			Convey("Config policy is returned", func() {
+				policy, err := c.GetConfigPolicy()
+				So(err, ShouldBeNil)
+				So(policy, ShouldNotBeNil)
+			})
+
+			Convey("Catalog contains interfaces of fixture procfs", func() {
+				mts, err := c.GetMetricTypes(plugin.PluginConfigType{ConfigDataNode: node})
+				So(err, ShouldBeNil)
+				namespaces := []string{}
+				for _, m := range mts {
+					namespaces = append(namespaces, strings.Join(m.Namespace(), "/"))
+				}
+				So(namespaces, ShouldContain, "intel/procfs/iface/eth0/bytes_recv")
+				So(namespaces, ShouldContain, "intel/procfs/iface/lo/packets_recv")
+			})
+
+			Convey("Metrics of fixture procfs are collected", func() {
+				mts := []core.Metric{
+					plugin.PluginMetricType{Namespace_: []string{"intel", "procfs", "iface", "eth0", "bytes_recv"}, Config_: node},
+				}
+				metrics, err := c.CollectMetrics(mts)
+				So(err, ShouldBeNil)
+				values := []interface{}{}
+				for _, m := range metrics {
+					So(m.Namespace(), ShouldResemble, mts[0].Namespace())
+					values = append(values, m.Data())
+				}
+				So(values, ShouldContain, int64(90210331))
+			})
+
+			Convey("Plugin is stopped on request", func() {
+				So(c.Kill("test finished"), ShouldBeNil)
+			})
+		})
+	})
+}
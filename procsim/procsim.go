@@ -0,0 +1,436 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package procsim simulates evolving /proc/net/dev and /sys/class/net trees
+// driven by a scenario script, it is meant for tests of stateful features.
+//
+// Scenario is a list of commands, one per line, empty lines and lines
+// starting with # are ignored:
+//
+//	add <iface> [<attr>=<value> ...]     adds interface with sysfs attributes
+//	del <iface>                          removes interface
+//	rename <iface> <name>                renames interface keeping its counters
+//	rate <iface> <stat>=<n> ...          sets counter increase per second
+//	set <iface> <stat>=<n> ...           sets counter values
+//	attr <iface> <attr>=<value> ...      sets sysfs attributes
+//	width <iface> <bits>                 sets counter width, counters wrap at 2^bits
+//	reset <iface>                        zeroes all counters
+//	advance <duration>                   moves clock and counters forward, e.g. 10s
+//	collect                              ends step of the scenario
+//
+// Statistic names are the ones published by the plugin, e.g. bytes_recv.
+package procsim
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats are names of /proc/net/dev columns in their order
+var Stats = []string{
+	"bytes_recv", "packets_recv", "errs_recv", "drop_recv",
+	"fifo_recv", "frame_recv", "compressed_recv", "multicast_recv",
+	"bytes_sent", "packets_sent", "errs_sent", "drop_sent",
+	"fifo_sent", "colls_sent", "carrier_sent", "compressed_sent",
+}
+
+// sysfsStats are names of sysfs statistics in order of Stats
+var sysfsStats = []string{
+	"rx_bytes", "rx_packets", "rx_errors", "rx_dropped",
+	"rx_fifo_errors", "rx_frame_errors", "rx_compressed", "multicast",
+	"tx_bytes", "tx_packets", "tx_errors", "tx_dropped",
+	"tx_fifo_errors", "collisions", "tx_carrier_errors", "tx_compressed",
+}
+
+// defaultAttrs are sysfs attributes of added interfaces
+var defaultAttrs = map[string]string{
+	"operstate": "up",
+	"carrier":   "1",
+	"mtu":       "1500",
+	"type":      "1",
+	"flags":     "0x1003",
+}
+
+// Interface is simulated network interface
+type Interface struct {
+	Name  string
+	Index int
+	// Counters are current values in order of Stats
+	Counters []uint64
+	// Rates are counter increases per second in order of Stats
+	Rates []uint64
+	// Width is number of bits of counters, 64 when not set
+	Width uint
+	// Attrs are sysfs attributes
+	Attrs map[string]string
+}
+
+// Simulator keeps simulated interfaces and writes them to its directory
+type Simulator struct {
+	// Dir contains proc/net/dev and sys/class/net trees
+	Dir       string
+	now       time.Time
+	ifaces    []*Interface
+	steps     [][]string
+	lastIndex int
+}
+
+// New creates simulator writing into dir with clock set to start
+func New(dir string, start time.Time) (*Simulator, error) {
+	s := &Simulator{Dir: dir, now: start}
+	if err := s.Write(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ProcNetDev returns path of simulated /proc/net/dev
+func (s *Simulator) ProcNetDev() string {
+	return filepath.Join(s.Dir, "proc", "net", "dev")
+}
+
+// SysClassNet returns path of simulated /sys/class/net
+func (s *Simulator) SysClassNet() string {
+	return filepath.Join(s.Dir, "sys", "class", "net")
+}
+
+// Now returns time of simulated clock
+func (s *Simulator) Now() time.Time {
+	return s.now
+}
+
+// Interface returns simulated interface, nil when it does not exist
+func (s *Simulator) Interface(name string) *Interface {
+	for _, i := range s.ifaces {
+		if i.Name == name {
+			return i
+		}
+	}
+	return nil
+}
+
+// Load parses scenario and splits it to steps ended by collect commands
+func (s *Simulator) Load(script string) error {
+	step := []string{}
+	for n, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, ok := commands[strings.Fields(line)[0]]; !ok && line != "collect" {
+			return fmt.Errorf("Unknown command at line %d {%s}", n+1, line)
+		}
+		if line == "collect" {
+			s.steps = append(s.steps, step)
+			step = []string{}
+			continue
+		}
+		step = append(step, line)
+	}
+	if len(step) > 0 {
+		s.steps = append(s.steps, step)
+	}
+	return nil
+}
+
+// Next executes next step of loaded scenario and writes the trees,
+// it returns false when there are no more steps
+func (s *Simulator) Next() (bool, error) {
+	if len(s.steps) == 0 {
+		return false, nil
+	}
+	step := s.steps[0]
+	s.steps = s.steps[1:]
+	for _, cmd := range step {
+		if err := s.exec(cmd); err != nil {
+			return false, err
+		}
+	}
+	return true, s.Write()
+}
+
+// Exec executes single command and writes the trees
+func (s *Simulator) Exec(cmd string) error {
+	if err := s.exec(cmd); err != nil {
+		return err
+	}
+	return s.Write()
+}
+
+var commands = map[string]func(s *Simulator, args []string) error{
+	"add":     (*Simulator).add,
+	"del":     (*Simulator).del,
+	"rename":  (*Simulator).rename,
+	"rate":    (*Simulator).rate,
+	"set":     (*Simulator).set,
+	"attr":    (*Simulator).attr,
+	"width":   (*Simulator).width,
+	"reset":   (*Simulator).reset,
+	"advance": (*Simulator).advance,
+}
+
+func (s *Simulator) exec(cmd string) error {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return nil
+	}
+	f, ok := commands[fields[0]]
+	if !ok {
+		return fmt.Errorf("Unknown command {%s}", cmd)
+	}
+	if err := f(s, fields[1:]); err != nil {
+		return fmt.Errorf("Cannot execute {%s}, %v", cmd, err)
+	}
+	return nil
+}
+
+func (s *Simulator) add(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("interface name is missing")
+	}
+	if s.Interface(args[0]) != nil {
+		return fmt.Errorf("interface already exists")
+	}
+	s.lastIndex++
+	i := &Interface{
+		Name:     args[0],
+		Index:    s.lastIndex,
+		Counters: make([]uint64, len(Stats)),
+		Rates:    make([]uint64, len(Stats)),
+		Attrs:    map[string]string{},
+	}
+	for k, v := range defaultAttrs {
+		i.Attrs[k] = v
+	}
+	s.ifaces = append(s.ifaces, i)
+	return s.attr(args)
+}
+
+func (s *Simulator) del(args []string) error {
+	i, err := s.lookup(args)
+	if err != nil {
+		return err
+	}
+	for n := range s.ifaces {
+		if s.ifaces[n] == i {
+			s.ifaces = append(s.ifaces[:n], s.ifaces[n+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) rename(args []string) error {
+	i, err := s.lookup(args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("new name is missing")
+	}
+	if s.Interface(args[1]) != nil {
+		return fmt.Errorf("interface %s already exists", args[1])
+	}
+	i.Name = args[1]
+	return nil
+}
+
+func (s *Simulator) rate(args []string) error {
+	return s.counters(args, func(i *Interface, n int, v uint64) { i.Rates[n] = v })
+}
+
+func (s *Simulator) set(args []string) error {
+	return s.counters(args, func(i *Interface, n int, v uint64) { i.Counters[n] = i.wrap(v) })
+}
+
+func (s *Simulator) attr(args []string) error {
+	i, err := s.lookup(args)
+	if err != nil {
+		return err
+	}
+	for _, arg := range args[1:] {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("wrong attribute {%s}", arg)
+		}
+		i.Attrs[kv[0]] = kv[1]
+	}
+	return nil
+}
+
+func (s *Simulator) width(args []string) error {
+	i, err := s.lookup(args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("counter width is missing")
+	}
+	bits, err := strconv.ParseUint(args[1], 10, 8)
+	if err != nil || bits == 0 || bits > 64 {
+		return fmt.Errorf("wrong counter width {%s}", args[1])
+	}
+	i.Width = uint(bits)
+	for n, v := range i.Counters {
+		i.Counters[n] = i.wrap(v)
+	}
+	return nil
+}
+
+func (s *Simulator) reset(args []string) error {
+	i, err := s.lookup(args)
+	if err != nil {
+		return err
+	}
+	for n := range i.Counters {
+		i.Counters[n] = 0
+	}
+	return nil
+}
+
+func (s *Simulator) advance(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("duration is missing")
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return fmt.Errorf("clock cannot go backwards")
+	}
+	s.Advance(d)
+	return nil
+}
+
+// Advance moves clock forward and increases counters by their rates
+func (s *Simulator) Advance(d time.Duration) {
+	s.now = s.now.Add(d)
+	for _, i := range s.ifaces {
+		for n, r := range i.Rates {
+			inc := r * uint64(d/time.Millisecond) / 1000
+			i.Counters[n] = i.wrap(i.Counters[n] + inc)
+		}
+	}
+}
+
+// wrap truncates counter value to width of interface counters
+func (i *Interface) wrap(v uint64) uint64 {
+	if i.Width == 0 || i.Width >= 64 {
+		return v
+	}
+	return v & (1<<i.Width - 1)
+}
+
+// lookup returns interface named by first argument
+func (s *Simulator) lookup(args []string) (*Interface, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("interface name is missing")
+	}
+	i := s.Interface(args[0])
+	if i == nil {
+		return nil, fmt.Errorf("interface %s does not exist", args[0])
+	}
+	return i, nil
+}
+
+// counters parses stat=value arguments following interface name
+func (s *Simulator) counters(args []string, f func(i *Interface, n int, v uint64)) error {
+	i, err := s.lookup(args)
+	if err != nil {
+		return err
+	}
+	for _, arg := range args[1:] {
+		kv := strings.SplitN(arg, "=", 2)
+		n := statIndex(kv[0])
+		if len(kv) != 2 || n < 0 {
+			return fmt.Errorf("wrong counter {%s}", arg)
+		}
+		v, err := strconv.ParseUint(kv[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		f(i, n, v)
+	}
+	return nil
+}
+
+func statIndex(name string) int {
+	for n, stat := range Stats {
+		if stat == name {
+			return n
+		}
+	}
+	return -1
+}
+
+// Write replaces proc/net/dev and sys/class/net trees by current state
+func (s *Simulator) Write() error {
+	if err := os.MkdirAll(filepath.Dir(s.ProcNetDev()), 0755); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	b.WriteString("Inter-|   Receive                                                |  Transmit\n")
+	b.WriteString(" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n")
+	for _, i := range s.ifaces {
+		fmt.Fprintf(&b, "%6s:", i.Name)
+		for _, v := range i.Counters {
+			fmt.Fprintf(&b, " %d", v)
+		}
+		b.WriteString("\n")
+	}
+	if err := ioutil.WriteFile(s.ProcNetDev(), b.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(s.SysClassNet()); err != nil {
+		return err
+	}
+	for _, i := range s.ifaces {
+		dir := filepath.Join(s.SysClassNet(), i.Name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		attrs := map[string]string{"ifindex": strconv.Itoa(i.Index)}
+		for k, v := range i.Attrs {
+			attrs[k] = v
+		}
+		for k, v := range attrs {
+			if err := ioutil.WriteFile(filepath.Join(dir, k), []byte(v+"\n"), 0644); err != nil {
+				return err
+			}
+		}
+		stats := filepath.Join(dir, "statistics")
+		if err := os.MkdirAll(stats, 0755); err != nil {
+			return err
+		}
+		for n, v := range i.Counters {
+			if err := ioutil.WriteFile(filepath.Join(stats, sysfsStats[n]), []byte(strconv.FormatUint(v, 10)+"\n"), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
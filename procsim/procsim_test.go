@@ -0,0 +1,142 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package procsim
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var start = time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func readFile(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func TestSimulator(t *testing.T) {
+	Convey("Given simulator with loaded scenario", t, func() {
+		dir, err := ioutil.TempDir("", "procsim")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		s, err := New(dir, start)
+		So(err, ShouldBeNil)
+
+		err = s.Load(`
+			# eth0 receives 1000 bytes per second
+			add eth0 speed=1000
+			rate eth0 bytes_recv=1000 packets_recv=10
+			collect
+			advance 10s
+			collect
+			width eth0 32
+			set eth0 bytes_recv=4294967000
+			advance 1s
+			collect
+			rename eth0 eth1
+			attr eth1 operstate=down
+			add eth2
+			collect
+			del eth1
+		`)
+		So(err, ShouldBeNil)
+
+		Convey("When steps are executed", func() {
+			ok, err := s.Next()
+			So(ok, ShouldBeTrue)
+			So(err, ShouldBeNil)
+			dev := readFile(s.ProcNetDev())
+
+			Convey("Trees are written", func() {
+				lines := strings.Split(dev, "\n")
+				So(len(lines), ShouldEqual, 4)
+				So(lines[2], ShouldEqual, "  eth0: 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0")
+				So(readFile(filepath.Join(s.SysClassNet(), "eth0", "speed")), ShouldEqual, "1000\n")
+				So(readFile(filepath.Join(s.SysClassNet(), "eth0", "operstate")), ShouldEqual, "up\n")
+				So(readFile(filepath.Join(s.SysClassNet(), "eth0", "ifindex")), ShouldEqual, "1\n")
+			})
+
+			Convey("Clock and counters move forward", func() {
+				s.Next()
+				So(s.Now(), ShouldResemble, start.Add(10*time.Second))
+				So(s.Interface("eth0").Counters[0], ShouldEqual, 10000)
+				So(s.Interface("eth0").Counters[1], ShouldEqual, 100)
+				So(readFile(filepath.Join(s.SysClassNet(), "eth0", "statistics", "rx_bytes")), ShouldEqual, "10000\n")
+			})
+
+			Convey("32-bit counters wrap", func() {
+				s.Next()
+				s.Next()
+				So(s.Interface("eth0").Counters[0], ShouldEqual, 704)
+			})
+
+			Convey("Interfaces are renamed, added and removed", func() {
+				s.Next()
+				s.Next()
+				s.Next()
+				So(s.Interface("eth1"), ShouldNotBeNil)
+				So(readFile(filepath.Join(s.SysClassNet(), "eth1", "operstate")), ShouldEqual, "down\n")
+				So(readFile(filepath.Join(s.SysClassNet(), "eth2", "ifindex")), ShouldEqual, "2\n")
+
+				ok, err := s.Next()
+				So(ok, ShouldBeTrue)
+				So(err, ShouldBeNil)
+				So(s.Interface("eth1"), ShouldBeNil)
+				So(readFile(s.ProcNetDev()), ShouldNotContainSubstring, "eth1")
+				_, err = os.Stat(filepath.Join(s.SysClassNet(), "eth1"))
+				So(os.IsNotExist(err), ShouldBeTrue)
+
+				ok, _ = s.Next()
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given wrong scenario", t, func() {
+		dir, err := ioutil.TempDir("", "procsim")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		s, err := New(dir, start)
+		So(err, ShouldBeNil)
+
+		Convey("Unknown commands are reported when loaded", func() {
+			So(s.Load("add eth0\nexplode eth0"), ShouldNotBeNil)
+		})
+
+		Convey("Wrong arguments are reported when executed", func() {
+			So(s.Exec("rate eth0 bytes_recv=1"), ShouldNotBeNil)
+			So(s.Exec("add eth0"), ShouldBeNil)
+			So(s.Exec("rate eth0 bogus=1"), ShouldNotBeNil)
+			So(s.Exec("width eth0 65"), ShouldNotBeNil)
+			So(s.Exec("advance -1s"), ShouldNotBeNil)
+		})
+	})
+}
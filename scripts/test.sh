@@ -20,8 +20,8 @@ if [[ $TEST_SUITE == "unit" ]]; then
 	go get github.com/stretchr/testify
 	
 	COVERALLS_TOKEN=t47LG6BQsfLwb9WxB56hXUezvwpED6D11
-	TEST_DIRS="main.go iface/"
-	VET_DIRS=". ./iface/..."
+	TEST_DIRS="main.go standalone.go manifest.go validate.go iface/ otlp/ procsim/ sflow/"
+	VET_DIRS=". ./iface/... ./otlp/... ./procsim/... ./sflow/..."
 
 	set -e
 
@@ -81,4 +81,9 @@ if [[ $TEST_SUITE == "unit" ]]; then
 	#         sleep 30
 	#     done
 	# fi
+elif [[ $TEST_SUITE == "integration" ]]; then
+	go get github.com/smartystreets/goconvey/convey
+
+	# builds the plugin and talks to it over snap plugin RPC
+	go test --tags=integration -v .
 fi
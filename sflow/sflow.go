@@ -0,0 +1,278 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sflow encodes sFlow version 5 datagrams with generic interface
+// counter samples and raw packet header flow samples and sends them to collector
+package sflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// Version of sFlow datagrams
+	Version = 5
+	// DefaultPort of sFlow collector
+	DefaultPort = 6343
+	// MaxDatagramSize keeps datagrams within Ethernet MTU
+	MaxDatagramSize = 1400
+
+	// HeaderEthernet is header protocol of frames starting with Ethernet header
+	HeaderEthernet = 1
+	// HeaderIPv4 is header protocol of packets starting with IPv4 header
+	HeaderIPv4 = 11
+	// HeaderIPv6 is header protocol of packets starting with IPv6 header
+	HeaderIPv6 = 12
+
+	// Unknown is value of 32 bit counters which are not available
+	Unknown = 0xffffffff
+
+	addressIPv4 = 1
+	addressIPv6 = 2
+
+	formatFlowSample    = 1
+	formatCounterSample = 2
+	formatRawHeader     = 1
+	formatIfCounters    = 1
+)
+
+// IfCounters holds generic interface counters (RFC 2233 based) of single interface
+type IfCounters struct {
+	Index     uint32
+	Type      uint32
+	Speed     uint64
+	Direction uint32
+	// Status has bit 0 set when interface is admin up and bit 1 when oper up
+	Status uint32
+
+	InOctets        uint64
+	InUcastPkts     uint32
+	InMulticastPkts uint32
+	InBroadcastPkts uint32
+	InDiscards      uint32
+	InErrors        uint32
+	InUnknownProtos uint32
+
+	OutOctets        uint64
+	OutUcastPkts     uint32
+	OutMulticastPkts uint32
+	OutBroadcastPkts uint32
+	OutDiscards      uint32
+	OutErrors        uint32
+
+	PromiscuousMode uint32
+}
+
+// FlowSample holds header of single sampled packet
+type FlowSample struct {
+	// SourceIndex is ifIndex of interface the packet was sampled on
+	SourceIndex  uint32
+	SamplingRate uint32
+	SamplePool   uint32
+	Drops        uint32
+	Input        uint32
+	Output       uint32
+
+	HeaderProtocol uint32
+	FrameLength    uint32
+	Header         []byte
+}
+
+// Exporter packs samples into datagrams and sends them to sFlow collector
+type Exporter struct {
+	conn  net.Conn
+	agent net.IP
+	start time.Time
+
+	seq        uint32
+	counterSeq map[uint32]uint32
+	flowSeq    map[uint32]uint32
+}
+
+// NewExporter creates exporter sending datagrams to collector address,
+// default sFlow port is used when address has no port; when agent address
+// is nil local address of collector connection is used
+func NewExporter(collector string, agent net.IP) (*Exporter, error) {
+	if _, _, err := net.SplitHostPort(collector); err != nil {
+		collector = net.JoinHostPort(collector, strconv.Itoa(DefaultPort))
+	}
+	conn, err := net.Dial("udp", collector)
+	if err != nil {
+		return nil, err
+	}
+	if agent == nil {
+		agent = conn.LocalAddr().(*net.UDPAddr).IP
+	}
+	return &Exporter{
+		conn:       conn,
+		agent:      agent,
+		start:      time.Now(),
+		counterSeq: map[uint32]uint32{},
+		flowSeq:    map[uint32]uint32{},
+	}, nil
+}
+
+// Export sends counter and flow samples in as few datagrams as possible
+func (e *Exporter) Export(counters []IfCounters, flows []FlowSample) error {
+	samples := [][]byte{}
+	for _, f := range flows {
+		e.flowSeq[f.SourceIndex]++
+		samples = append(samples, encodeFlowSample(e.flowSeq[f.SourceIndex], f))
+	}
+	for _, c := range counters {
+		e.counterSeq[c.Index]++
+		samples = append(samples, encodeCounterSample(e.counterSeq[c.Index], c))
+	}
+
+	for len(samples) > 0 {
+		n, size := 0, datagramHeaderSize(e.agent)
+		for n < len(samples) && (n == 0 || size+len(samples[n]) <= MaxDatagramSize) {
+			size += len(samples[n])
+			n++
+		}
+
+		e.seq++
+		uptime := uint32(time.Since(e.start) / time.Millisecond)
+		dgram, err := encodeDatagram(e.agent, e.seq, uptime, samples[:n])
+		if err != nil {
+			return err
+		}
+		if _, err := e.conn.Write(dgram); err != nil {
+			return err
+		}
+		samples = samples[n:]
+	}
+	return nil
+}
+
+// Close closes connection to collector
+func (e *Exporter) Close() error {
+	return e.conn.Close()
+}
+
+func datagramHeaderSize(agent net.IP) int {
+	if agent.To4() != nil {
+		return 28
+	}
+	return 40
+}
+
+func encodeDatagram(agent net.IP, seq, uptime uint32, samples [][]byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	put(buf, Version)
+	if ip4 := agent.To4(); ip4 != nil {
+		put(buf, addressIPv4)
+		buf.Write(ip4)
+	} else if ip6 := agent.To16(); ip6 != nil {
+		put(buf, addressIPv6)
+		buf.Write(ip6)
+	} else {
+		return nil, fmt.Errorf("Wrong sFlow agent address {%v}", agent)
+	}
+	// sub agent id
+	put(buf, 0)
+	put(buf, seq)
+	put(buf, uptime)
+	put(buf, uint32(len(samples)))
+	for _, s := range samples {
+		buf.Write(s)
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeCounterSample(seq uint32, c IfCounters) []byte {
+	rec := &bytes.Buffer{}
+	put(rec, c.Index)
+	put(rec, c.Type)
+	put(rec, c.Speed)
+	put(rec, c.Direction)
+	put(rec, c.Status)
+	put(rec, c.InOctets)
+	put(rec, c.InUcastPkts)
+	put(rec, c.InMulticastPkts)
+	put(rec, c.InBroadcastPkts)
+	put(rec, c.InDiscards)
+	put(rec, c.InErrors)
+	put(rec, c.InUnknownProtos)
+	put(rec, c.OutOctets)
+	put(rec, c.OutUcastPkts)
+	put(rec, c.OutMulticastPkts)
+	put(rec, c.OutBroadcastPkts)
+	put(rec, c.OutDiscards)
+	put(rec, c.OutErrors)
+	put(rec, c.PromiscuousMode)
+
+	sample := &bytes.Buffer{}
+	put(sample, seq)
+	put(sample, c.Index)
+	// number of records
+	put(sample, 1)
+	sample.Write(wrap(formatIfCounters, rec.Bytes()))
+	return wrap(formatCounterSample, sample.Bytes())
+}
+
+func encodeFlowSample(seq uint32, f FlowSample) []byte {
+	header := f.Header
+	if pad := len(header) % 4; pad != 0 {
+		header = append(append([]byte(nil), header...), make([]byte, 4-pad)...)
+	}
+
+	rec := &bytes.Buffer{}
+	put(rec, f.HeaderProtocol)
+	put(rec, f.FrameLength)
+	// bytes stripped from the end of frame, e.g. FCS
+	put(rec, 0)
+	put(rec, uint32(len(f.Header)))
+	rec.Write(header)
+
+	sample := &bytes.Buffer{}
+	put(sample, seq)
+	put(sample, f.SourceIndex)
+	put(sample, f.SamplingRate)
+	put(sample, f.SamplePool)
+	put(sample, f.Drops)
+	put(sample, f.Input)
+	put(sample, f.Output)
+	// number of records
+	put(sample, 1)
+	sample.Write(wrap(formatRawHeader, rec.Bytes()))
+	return wrap(formatFlowSample, sample.Bytes())
+}
+
+// wrap prepends data format of standard enterprise and data length
+func wrap(format uint32, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	put(buf, format)
+	put(buf, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func put(buf *bytes.Buffer, v interface{}) {
+	switch t := v.(type) {
+	case int:
+		v = uint32(t)
+	}
+	binary.Write(buf, binary.BigEndian, v)
+}
@@ -0,0 +1,187 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// datagram is decoded sFlow datagram
+type datagram struct {
+	version  uint32
+	agent    net.IP
+	seq      uint32
+	counters []IfCounters
+	flows    []FlowSample
+}
+
+func decodeDatagram(b []byte) (*datagram, error) {
+	r := bytes.NewReader(b)
+	get := func(v interface{}) error { return binary.Read(r, binary.BigEndian, v) }
+
+	d := &datagram{}
+	var addrType, subAgent, uptime, n uint32
+	get(&d.version)
+	get(&addrType)
+	d.agent = make(net.IP, 4)
+	if addrType == addressIPv6 {
+		d.agent = make(net.IP, 16)
+	}
+	get(&d.agent)
+	get(&subAgent)
+	get(&d.seq)
+	get(&uptime)
+	if err := get(&n); err != nil {
+		return nil, err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		var format, length, seq, source, records uint32
+		get(&format)
+		get(&length)
+		get(&seq)
+		get(&source)
+		switch format {
+		case formatCounterSample:
+			get(&records)
+			var recFormat, recLength uint32
+			get(&recFormat)
+			get(&recLength)
+			c := IfCounters{}
+			if err := get(&c); err != nil {
+				return nil, err
+			}
+			d.counters = append(d.counters, c)
+		case formatFlowSample:
+			f := FlowSample{SourceIndex: source}
+			get(&f.SamplingRate)
+			get(&f.SamplePool)
+			get(&f.Drops)
+			get(&f.Input)
+			get(&f.Output)
+			get(&records)
+			var recFormat, recLength, stripped, headerLength uint32
+			get(&recFormat)
+			get(&recLength)
+			get(&f.HeaderProtocol)
+			get(&f.FrameLength)
+			get(&stripped)
+			get(&headerLength)
+			f.Header = make([]byte, recLength-16)
+			if err := get(&f.Header); err != nil {
+				return nil, err
+			}
+			f.Header = f.Header[:headerLength]
+			d.flows = append(d.flows, f)
+		}
+	}
+	return d, nil
+}
+
+func TestExporter(t *testing.T) {
+	Convey("Given sFlow exporter sending to local UDP receiver", t, func() {
+		rcv, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer rcv.Close()
+
+		exporter, err := NewExporter(rcv.LocalAddr().String(), nil)
+		So(err, ShouldBeNil)
+		defer exporter.Close()
+
+		receive := func() *datagram {
+			buf := make([]byte, 65536)
+			rcv.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := rcv.ReadFrom(buf)
+			So(err, ShouldBeNil)
+			So(n, ShouldBeLessThanOrEqualTo, MaxDatagramSize)
+			d, err := decodeDatagram(buf[:n])
+			So(err, ShouldBeNil)
+			return d
+		}
+
+		counters := IfCounters{
+			Index:           2,
+			Type:            6,
+			Speed:           10000000000,
+			Direction:       1,
+			Status:          3,
+			InOctets:        1412848320,
+			InUcastPkts:     12238775,
+			InBroadcastPkts: Unknown,
+			OutOctets:       6434234393,
+			OutUcastPkts:    17015516,
+		}
+		flow := FlowSample{
+			SourceIndex:    2,
+			SamplingRate:   100,
+			SamplePool:     1000,
+			Input:          2,
+			HeaderProtocol: HeaderEthernet,
+			FrameLength:    142,
+			Header:         []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 8, 0},
+		}
+
+		Convey("When counter and flow samples are exported", func() {
+			err := exporter.Export([]IfCounters{counters}, []FlowSample{flow})
+			So(err, ShouldBeNil)
+
+			Convey("Single datagram with both samples is received", func() {
+				d := receive()
+				So(d.version, ShouldEqual, 5)
+				So(d.agent.String(), ShouldEqual, "127.0.0.1")
+				So(d.seq, ShouldEqual, 1)
+				So(len(d.counters), ShouldEqual, 1)
+				So(d.counters[0], ShouldResemble, counters)
+				So(len(d.flows), ShouldEqual, 1)
+				So(d.flows[0], ShouldResemble, flow)
+			})
+		})
+
+		Convey("When samples do not fit into single datagram", func() {
+			many := []IfCounters{}
+			for i := 1; i <= 30; i++ {
+				c := counters
+				c.Index = uint32(i)
+				many = append(many, c)
+			}
+			err := exporter.Export(many, nil)
+			So(err, ShouldBeNil)
+
+			Convey("They are split into sequenced datagrams", func() {
+				received := []IfCounters{}
+				for seq := uint32(1); len(received) < 30; seq++ {
+					d := receive()
+					So(d.seq, ShouldEqual, seq)
+					received = append(received, d.counters...)
+				}
+				So(len(received), ShouldEqual, 30)
+				So(received[29].Index, ShouldEqual, 30)
+			})
+		})
+	})
+}
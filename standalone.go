@@ -0,0 +1,131 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/intelsdi-x/snap-plugin-collector-interface/iface"
+	"github.com/intelsdi-x/snap-plugin-collector-interface/otlp"
+	"github.com/intelsdi-x/snap-plugin-collector-interface/sflow"
+)
+
+// standalone runs the plugin outside of snap and exports interface
+// statistics on its own every interval
+func standalone(args []string) error {
+	fs := flag.NewFlagSet("standalone", flag.ContinueOnError)
+	interval := fs.Duration("interval", 10*time.Second, "export interval")
+	sflowCollector := fs.String("sflow", "", "sFlow collector address host[:port]")
+	sflowAgent := fs.String("sflow-agent", "", "sFlow agent address, defaults to local address used to reach collector")
+	sflowSampling := fs.Int("sflow-sampling", 0, "sample 1 in N packets for sFlow flow samples, 0 disables flow samples")
+	sflowFilter := fs.String("sflow-filter", "", "BPF filter of sampled packets in `tcpdump -ddd` format with lines joined by commas")
+	otlpEndpoint := fs.String("otlp", "", "OTLP receiver endpoint host[:port] or URL")
+	otlpProtocol := fs.String("otlp-protocol", otlp.ProtocolGRPC, "OTLP protocol, grpc or http")
+	otlpTags := fs.Bool("otlp-tags", false, "add tunnel, VM and VRF tags of interfaces to OTLP data point attributes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *interval <= 0 {
+		return fmt.Errorf("Wrong export interval {%v}, it must be positive", *interval)
+	}
+	if *sflowCollector == "" && *otlpEndpoint == "" {
+		return fmt.Errorf("No exporter configured, use -sflow or -otlp")
+	}
+
+	var exporter *sflow.Exporter
+	var sampler *iface.FlowSampler
+	var err error
+	if *sflowCollector != "" {
+		var agent net.IP
+		if *sflowAgent != "" {
+			if agent = net.ParseIP(*sflowAgent); agent == nil {
+				return fmt.Errorf("Wrong sFlow agent address {%s}", *sflowAgent)
+			}
+		}
+		if exporter, err = sflow.NewExporter(*sflowCollector, agent); err != nil {
+			return err
+		}
+		defer exporter.Close()
+
+		if *sflowSampling > 0 {
+			if sampler, err = iface.NewFlowSampler(*sflowSampling, *sflowFilter); err != nil {
+				return err
+			}
+			defer sampler.Close()
+		}
+	}
+
+	var otlpExporter *otlp.Exporter
+	var otlpReader *iface.OTLPReader
+	if *otlpEndpoint != "" {
+		host, _ := os.Hostname()
+		resource := map[string]string{
+			"host.name":    host,
+			"os.type":      "linux",
+			"service.name": otlp.ScopeName,
+		}
+		if otlpExporter, err = otlp.NewExporter(*otlpEndpoint, *otlpProtocol, resource); err != nil {
+			return err
+		}
+		defer otlpExporter.Close()
+		otlpReader = iface.NewOTLPReader(*otlpTags)
+	}
+
+	tick := time.Tick(*interval)
+	for {
+		<-tick
+		if exporter != nil {
+			exportSFlow(exporter, sampler)
+		}
+		if otlpExporter != nil {
+			metrics, err := otlpReader.Metrics()
+			if err != nil {
+				log.Error("Cannot read interface metrics, ", err)
+			} else if err := otlpExporter.Export(metrics); err != nil {
+				log.Error("Cannot export OTLP metrics, ", err)
+			}
+		}
+	}
+}
+
+// exportSFlow exports counters of all interfaces together with flow samples
+func exportSFlow(exporter *sflow.Exporter, sampler *iface.FlowSampler) {
+	counters, err := iface.SFlowCounters()
+	if err != nil {
+		log.Error("Cannot read interface counters, ", err)
+		return
+	}
+	flows := []sflow.FlowSample{}
+	if sampler != nil {
+		flows = sampler.Flows()
+	}
+	if err := exporter.Export(counters, flows); err != nil {
+		log.Error("Cannot export sFlow datagrams, ", err)
+	}
+}
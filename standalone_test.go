@@ -0,0 +1,46 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStandalone(t *testing.T) {
+	Convey("Given standalone flags", t, func() {
+		Convey("Interval which is not positive is rejected", func() {
+			for _, interval := range []string{"0s", "-10s"} {
+				err := standalone([]string{"-sflow", "127.0.0.1", "-interval", interval})
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "Wrong export interval")
+			}
+		})
+
+		Convey("Missing exporter is reported", func() {
+			err := standalone([]string{"-interval", "1s"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "No exporter configured")
+		})
+	})
+}
@@ -0,0 +1,111 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-collector-interface/iface"
+)
+
+// validate checks plugin config and prints interfaces, sources and metrics
+// it produces on this host, it fails on config errors and with -strict
+// also on warnings
+func validate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	config := configItems{}
+	fs.Var(config, "config", "plugin config item key=value, may be repeated")
+	interfaces := fs.String("interfaces", "*", "comma separated interface name patterns expected to match, e.g. eth*,lo")
+	stats := fs.String("stats", "*", "comma separated statistic patterns expected to match, e.g. bytes_*,errs_recv")
+	strict := fs.Bool("strict", false, "fail also when there are warnings")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := iface.Validate(config.node())
+	if err != nil {
+		return err
+	}
+	if len(report.Errors) == 0 {
+		checkPatterns(report, strings.Split(*interfaces, ","), strings.Split(*stats, ","))
+	}
+	printReport(report, out)
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("Config is invalid, %d errors", len(report.Errors))
+	}
+	if *strict && len(report.Warnings) > 0 {
+		return fmt.Errorf("Config has %d warnings", len(report.Warnings))
+	}
+	return nil
+}
+
+// checkPatterns warns about interface and statistic patterns
+// which match no interface or no metric of matched interfaces
+func checkPatterns(report *iface.Report, interfaces, stats []string) {
+	for _, p := range interfaces {
+		found := false
+		for _, name := range report.Interfaces {
+			found = found || matchAny([]string{p}, name)
+		}
+		if !found {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Interface pattern {%s} matches no interface", p))
+		}
+	}
+	for _, p := range stats {
+		found := false
+		for _, m := range report.Metrics {
+			ns := strings.Split(strings.TrimPrefix(m, "/"), "/")
+			found = found || len(ns) > 4 && matchAny(interfaces, ns[3]) && matchAny([]string{p}, strings.Join(ns[4:], "/"))
+		}
+		if !found {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Statistic pattern {%s} matches no metric of matched interfaces", p))
+		}
+	}
+}
+
+func printReport(report *iface.Report, out io.Writer) {
+	for _, err := range report.Errors {
+		fmt.Fprintf(out, "error: %v\n", err)
+	}
+	if len(report.Errors) > 0 {
+		return
+	}
+	fmt.Fprintf(out, "interfaces: %s\n", strings.Join(report.Interfaces, " "))
+	fmt.Fprintln(out, "sources:")
+	for _, s := range report.Sources {
+		state := "disabled"
+		if s.Enabled && s.Err != nil {
+			state = "unavailable"
+		} else if s.Enabled {
+			state = "enabled"
+		}
+		fmt.Fprintf(out, "    %-18s %s\n", s.Name, state)
+	}
+	fmt.Fprintf(out, "metrics: %d\n", len(report.Metrics))
+	for _, w := range report.Warnings {
+		fmt.Fprintf(out, "warning: %s\n", w)
+	}
+}
@@ -0,0 +1,71 @@
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("Given host with loopback interface", t, func() {
+		Convey("When valid config is checked", func() {
+			var out bytes.Buffer
+			err := validate([]string{"-config", "max_interfaces=0", "-interfaces", "lo,wlan*", "-stats", "bytes_*,rtt_*"}, &out)
+
+			Convey("Report lists interfaces, sources and metrics", func() {
+				So(err, ShouldBeNil)
+				So(out.String(), ShouldContainSubstring, "interfaces: ")
+				So(out.String(), ShouldContainSubstring, " lo")
+				So(out.String(), ShouldContainSubstring, "    _tcp               disabled\n")
+				So(out.String(), ShouldContainSubstring, "metrics: ")
+			})
+
+			Convey("Patterns which match nothing are reported", func() {
+				So(out.String(), ShouldContainSubstring, "warning: Interface pattern {wlan*} matches no interface\n")
+				So(out.String(), ShouldContainSubstring, "warning: Statistic pattern {rtt_*} matches no metric of matched interfaces\n")
+				So(out.String(), ShouldNotContainSubstring, "{bytes_*}")
+			})
+		})
+
+		Convey("When warnings are not allowed", func() {
+			err := validate([]string{"-strict", "-config", "tcp_prefix_v4=16"}, &bytes.Buffer{})
+
+			Convey("Error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When config is invalid", func() {
+			var out bytes.Buffer
+			err := validate([]string{"-config", "tcp_info=yes"}, &out)
+
+			Convey("Error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(out.String(), ShouldStartWith, "error: ")
+				So(out.String(), ShouldNotContainSubstring, "metrics: ")
+			})
+		})
+	})
+}